@@ -0,0 +1,140 @@
+package basemodel
+
+import "context"
+
+// actorKey is the context key for the acting user
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor that performs the operation
+// HTTP middleware typically sets it from the authenticated user
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx by WithActor
+func ActorFromContext(ctx context.Context) (string, bool) {
+	actor, ok := ctx.Value(actorKey{}).(string)
+	return actor, ok && actor != ""
+}
+
+// AuditFields holds the actor of each change
+// Embed it inline next to BaseCollection or Base, together with any other
+// *Fields mixin; repositories stamp the fields from the context and leave a
+// field unchanged when the context carries no actor
+type AuditFields struct {
+	CreatedBy string `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	UpdatedBy string `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+	DeletedBy string `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+}
+
+// GetCreatedBy returns the actor that created the record
+func (a *AuditFields) GetCreatedBy() string {
+	return a.CreatedBy
+}
+
+// GetUpdatedBy returns the actor that last updated the record
+func (a *AuditFields) GetUpdatedBy() string {
+	return a.UpdatedBy
+}
+
+// GetDeletedBy returns the actor that soft deleted the record
+func (a *AuditFields) GetDeletedBy() string {
+	return a.DeletedBy
+}
+
+func (a *AuditFields) setCreatedBy(actor string) {
+	a.CreatedBy = actor
+}
+
+func (a *AuditFields) setUpdatedBy(actor string) {
+	a.UpdatedBy = actor
+}
+
+func (a *AuditFields) setDeletedBy(actor string) {
+	a.DeletedBy = actor
+}
+
+// AuditableCollection extends BaseCollection with the actor of each change
+// The *MetaContext methods read the actor from the context; when the context
+// carries no actor the corresponding field is left unchanged
+type AuditableCollection struct {
+	BaseCollection `bson:",inline"`
+	AuditFields    `bson:",inline"`
+}
+
+// SetInsertMetaContext sets the insert metadata and the CreatedBy actor
+func (a *AuditableCollection) SetInsertMetaContext(ctx context.Context) {
+	a.SetInsertMeta()
+	if actor, ok := ActorFromContext(ctx); ok {
+		a.CreatedBy = actor
+	}
+}
+
+// SetUpdateMetaContext sets the update metadata and the UpdatedBy actor
+func (a *AuditableCollection) SetUpdateMetaContext(ctx context.Context) {
+	a.SetUpdateMeta()
+	if actor, ok := ActorFromContext(ctx); ok {
+		a.UpdatedBy = actor
+	}
+}
+
+// SetDeleteMetaContext sets the soft delete metadata and the DeletedBy actor
+func (a *AuditableCollection) SetDeleteMetaContext(ctx context.Context) {
+	a.SetDeleteMeta()
+	if actor, ok := ActorFromContext(ctx); ok {
+		a.DeletedBy = actor
+	}
+}
+
+// ClearDeleteMetaContext restores a soft deleted record
+// It clears DeletedBy and records the restoring actor as UpdatedBy
+func (a *AuditableCollection) ClearDeleteMetaContext(ctx context.Context) {
+	a.ClearDeleteMeta()
+	a.DeletedBy = ""
+	if actor, ok := ActorFromContext(ctx); ok {
+		a.UpdatedBy = actor
+	}
+}
+
+// auditable is implemented by models that embed AuditFields
+type auditable interface {
+	setCreatedBy(actor string)
+	setUpdatedBy(actor string)
+	setDeletedBy(actor string)
+}
+
+// setInsertMeta applies the insert metadata, starts the version of versioned
+// models at 1 and records the creating actor of auditable models
+func setInsertMeta(ctx context.Context, m Model) {
+	m.SetInsertMeta()
+	if v, ok := m.(versioned); ok {
+		v.setVersion(1)
+	}
+	if a, ok := m.(auditable); ok {
+		if actor, ok := ActorFromContext(ctx); ok {
+			a.setCreatedBy(actor)
+		}
+	}
+}
+
+// setUpdateMeta applies the update metadata and records the updating actor
+// of auditable models
+func setUpdateMeta(ctx context.Context, m Model) {
+	m.SetUpdateMeta()
+	if a, ok := m.(auditable); ok {
+		if actor, ok := ActorFromContext(ctx); ok {
+			a.setUpdatedBy(actor)
+		}
+	}
+}
+
+// setDeleteMeta applies the soft delete metadata and records the deleting
+// actor of auditable models
+func setDeleteMeta(ctx context.Context, m Model) {
+	m.SetDeleteMeta()
+	if a, ok := m.(auditable); ok {
+		if actor, ok := ActorFromContext(ctx); ok {
+			a.setDeletedBy(actor)
+		}
+	}
+}
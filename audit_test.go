@@ -0,0 +1,111 @@
+package basemodel
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestInvoice is a test struct that embeds AuditableCollection
+type TestInvoice struct {
+	AuditableCollection `bson:",inline"`
+	Amount              int64 `json:"amount" bson:"amount"`
+}
+
+func TestActorFromContext(t *testing.T) {
+	if _, ok := ActorFromContext(context.Background()); ok {
+		t.Error("Expected no actor in an empty context")
+	}
+
+	ctx := WithActor(context.Background(), "alice")
+	actor, ok := ActorFromContext(ctx)
+	if !ok || actor != "alice" {
+		t.Errorf("Expected actor alice, got %q", actor)
+	}
+}
+
+func TestAuditableCollectionMetaContext(t *testing.T) {
+	invoice := &TestInvoice{Amount: 100}
+
+	invoice.SetInsertMetaContext(WithActor(context.Background(), "alice"))
+	if invoice.Oid.IsZero() || invoice.GetCreatedBy() != "alice" {
+		t.Errorf("Expected insert metadata with CreatedBy alice, got %q", invoice.GetCreatedBy())
+	}
+
+	invoice.SetUpdateMetaContext(WithActor(context.Background(), "bob"))
+	if invoice.UpdatedAt == nil || invoice.GetUpdatedBy() != "bob" {
+		t.Errorf("Expected update metadata with UpdatedBy bob, got %q", invoice.GetUpdatedBy())
+	}
+
+	invoice.SetDeleteMetaContext(WithActor(context.Background(), "carol"))
+	if !invoice.IsDeleted() || invoice.GetDeletedBy() != "carol" {
+		t.Errorf("Expected delete metadata with DeletedBy carol, got %q", invoice.GetDeletedBy())
+	}
+
+	invoice.ClearDeleteMetaContext(WithActor(context.Background(), "dave"))
+	if invoice.IsDeleted() || invoice.GetDeletedBy() != "" {
+		t.Error("Expected ClearDeleteMetaContext to clear DeletedAt and DeletedBy")
+	}
+	if invoice.GetUpdatedBy() != "dave" {
+		t.Errorf("Expected UpdatedBy dave after restore, got %q", invoice.GetUpdatedBy())
+	}
+
+	// Without an actor the fields are left unchanged
+	invoice.SetUpdateMetaContext(context.Background())
+	if invoice.GetUpdatedBy() != "dave" {
+		t.Errorf("Expected UpdatedBy to stay dave without an actor, got %q", invoice.GetUpdatedBy())
+	}
+}
+
+func TestRepositoryAuditable(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("create stamps created_by", func(mt *mtest.T) {
+		repo := NewRepository[TestInvoice](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		invoice := &TestInvoice{Amount: 100}
+		ctx := WithActor(context.Background(), "alice")
+		if err := repo.Create(ctx, invoice); err != nil {
+			mt.Fatalf("Create returned error: %v", err)
+		}
+
+		sent := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if sent.Lookup("created_by").StringValue() != "alice" {
+			mt.Errorf("Expected created_by alice, got %s", sent)
+		}
+	})
+
+	mt.Run("soft delete stamps deleted_by", func(mt *mtest.T) {
+		repo := NewRepository[TestInvoice](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		ctx := WithActor(context.Background(), "carol")
+		if err := repo.SoftDelete(ctx, primitive.NewObjectID().Hex()); err != nil {
+			mt.Fatalf("SoftDelete returned error: %v", err)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if update.Lookup("$set", "deleted_by").StringValue() != "carol" {
+			mt.Errorf("Expected deleted_by carol, got %s", update)
+		}
+	})
+
+	mt.Run("non auditable models ignore the actor", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		ctx := WithActor(context.Background(), "carol")
+		if err := repo.SoftDelete(ctx, primitive.NewObjectID().Hex()); err != nil {
+			mt.Fatalf("SoftDelete returned error: %v", err)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if _, err := update.LookupErr("$set", "deleted_by"); err == nil {
+			mt.Error("Expected no deleted_by for a model without AuditableCollection")
+		}
+	})
+}
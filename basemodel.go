@@ -6,6 +6,23 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Model is implemented by any struct that embeds BaseCollection or Base
+// Helpers can accept a Model to work with every base model without reflection
+type Model interface {
+	SetInsertMeta()
+	SetUpdateMeta()
+	SetDeleteMeta()
+	ClearDeleteMeta()
+	IsDeleted() bool
+	GetID() string
+	GetCreatedAt() time.Time
+	GetUpdatedAt() *time.Time
+	GetDeletedAt() *time.Time
+}
+
+// Ensure BaseCollection implements Model
+var _ Model = (*BaseCollection)(nil)
+
 // BaseCollection provides common fields and methods for MongoDB collections
 type BaseCollection struct {
 	Oid       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
@@ -17,7 +34,7 @@ type BaseCollection struct {
 // SetInsertMeta sets the metadata for insert operations
 // It generates a new ObjectID and sets the CreatedAt timestamp
 func (b *BaseCollection) SetInsertMeta() {
-	now := time.Now()
+	now := timeNow()
 	b.Oid = primitive.NewObjectID()
 	b.CreatedAt = now
 }
@@ -25,17 +42,25 @@ func (b *BaseCollection) SetInsertMeta() {
 // SetUpdateMeta sets the metadata for update operations
 // It sets the UpdatedAt timestamp
 func (b *BaseCollection) SetUpdateMeta() {
-	now := time.Now()
+	now := timeNow()
 	b.UpdatedAt = &now
 }
 
 // SetDeleteMeta sets the metadata for soft delete operations
 // It sets the DeletedAt timestamp for soft deletion
 func (b *BaseCollection) SetDeleteMeta() {
-	now := time.Now()
+	now := timeNow()
 	b.DeletedAt = &now
 }
 
+// ClearDeleteMeta restores a soft deleted record
+// It clears the DeletedAt timestamp and sets the UpdatedAt timestamp
+func (b *BaseCollection) ClearDeleteMeta() {
+	now := timeNow()
+	b.DeletedAt = nil
+	b.UpdatedAt = &now
+}
+
 // IsDeleted checks if the record is soft deleted
 func (b *BaseCollection) IsDeleted() bool {
 	return b.DeletedAt != nil
@@ -60,3 +85,22 @@ func (b *BaseCollection) GetUpdatedAt() *time.Time {
 func (b *BaseCollection) GetDeletedAt() *time.Time {
 	return b.DeletedAt
 }
+
+// documentID returns the raw ObjectID for use in repository filters
+func (b *BaseCollection) documentID() interface{} {
+	return b.Oid
+}
+
+// hasID reports whether an ObjectID was generated
+func (b *BaseCollection) hasID() bool {
+	return !b.Oid.IsZero()
+}
+
+// parseID converts an ObjectID hex string for use in repository filters
+func (b *BaseCollection) parseID(id string) (interface{}, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	return oid, nil
+}
@@ -0,0 +1,378 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultBulkChunkSize is the number of documents sent per bulk write
+// It stays well below the server's maxWriteBatchSize of 100,000 operations
+const DefaultBulkChunkSize = 1000
+
+// BulkOption configures a bulk operation
+type BulkOption func(*bulkConfig)
+
+// bulkConfig holds the settings applied by BulkOption
+type bulkConfig struct {
+	ordered   bool
+	chunkSize int
+}
+
+// Unordered lets the server continue past failed documents
+// By default bulk operations are ordered and stop at the first failure
+func Unordered() BulkOption {
+	return func(c *bulkConfig) {
+		c.ordered = false
+	}
+}
+
+// WithChunkSize overrides the number of documents sent per bulk write
+func WithChunkSize(n int) BulkOption {
+	return func(c *bulkConfig) {
+		if n > 0 {
+			c.chunkSize = n
+		}
+	}
+}
+
+// BulkItem is the outcome of one input document, at the same Index as the input
+// Err is nil when the write succeeded
+type BulkItem struct {
+	Index    int
+	ID       string
+	Upserted bool
+	Err      error
+}
+
+// BulkResult reports the counts and per-document outcomes of a bulk operation
+type BulkResult struct {
+	Items    []BulkItem
+	Inserted int64
+	Matched  int64
+	Modified int64
+	Upserted int64
+}
+
+// BulkErrors lists every failed document of a bulk operation
+type BulkErrors []BulkItem
+
+// Error implements the error interface
+func (e BulkErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, item := range e {
+		msgs[i] = fmt.Sprintf("index %d: %v", item.Index, item.Err)
+	}
+	return fmt.Sprintf("basemodel: %d bulk writes failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// failed collects the items that carry an error, or nil when all succeeded
+func (r *BulkResult) failed() error {
+	var errs BulkErrors
+	for _, item := range r.Items {
+		if item.Err != nil {
+			errs = append(errs, item)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// InsertMany sets the insert metadata on every model, validates them and
+// inserts them in chunks
+// Failed documents are reported in BulkErrors by input index; in ordered
+// mode the documents after the first failure carry ErrBulkAborted
+// Hooks run and history is recorded per document as in Create; errors from
+// AfterInsert or the history write are reported on the document's item
+func (r *Repository[T, PT]) InsertMany(ctx context.Context, models []PT, opts ...BulkOption) (*BulkResult, error) {
+	if _, err := tenantCondition(ctx, PT(new(T))); err != nil {
+		return nil, err
+	}
+
+	prepare := func(i int) (mongo.WriteModel, string, error) {
+		model := models[i]
+		if err := stampTenant(ctx, model); err != nil {
+			return nil, "", err
+		}
+		if err := beforeInsert(ctx, model); err != nil {
+			return nil, "", err
+		}
+		setInsertMeta(ctx, model)
+		if err := requireID(model); err != nil {
+			return nil, "", err
+		}
+		if err := Validate(model); err != nil {
+			return nil, model.GetID(), err
+		}
+		return mongo.NewInsertOneModel().SetDocument(model), model.GetID(), nil
+	}
+
+	complete := func(item *BulkItem) error {
+		model := models[item.Index]
+		if r.history != nil {
+			after, err := bson.Marshal(model)
+			if err != nil {
+				return err
+			}
+			if err := r.recordHistory(ctx, HistoryInsert, model.documentID(), nil, after); err != nil {
+				return err
+			}
+		}
+		return afterInsert(ctx, model)
+	}
+
+	return r.bulkWrite(ctx, len(models), opts, prepare, complete)
+}
+
+// BulkUpsert inserts or updates every model in chunks
+// filter returns the upsert filter of a model; when nil, models are matched by _id
+// Models without an ID get insert metadata first; created_at and _id are only
+// written when the document is inserted, the rest of the model is $set
+// When a custom filter matches an existing document, the model keeps its
+// generated ID and created_at; reload it to see the stored values
+//
+// BeforeInsert or BeforeUpdate runs depending on whether a document matches,
+// and AfterInsert or AfterUpdate once it was written; history records the
+// insert or update
+func (r *Repository[T, PT]) BulkUpsert(ctx context.Context, models []PT, filter func(PT) interface{}, opts ...BulkOption) (*BulkResult, error) {
+	if _, err := tenantCondition(ctx, PT(new(T))); err != nil {
+		return nil, err
+	}
+
+	befores := make([]bson.Raw, len(models))
+	prepare := func(i int) (mongo.WriteModel, string, error) {
+		model := models[i]
+		var match interface{}
+		if filter != nil {
+			match = filter(model)
+		}
+		before, err := r.beforeUpsert(ctx, match, model)
+		if err != nil {
+			return nil, model.GetID(), err
+		}
+		befores[i] = before
+
+		match, update, err := prepareUpsert(ctx, model, match)
+		if err != nil {
+			return nil, model.GetID(), err
+		}
+		query, err := r.scopedFilter(ctx, scopeActive, match)
+		if err != nil {
+			return nil, model.GetID(), err
+		}
+
+		return mongo.NewUpdateOneModel().
+			SetFilter(query).
+			SetUpdate(update).
+			SetUpsert(true), model.GetID(), nil
+	}
+
+	complete := func(item *BulkItem) error {
+		return r.afterUpsert(ctx, models[item.Index], befores[item.Index], nil, item.Upserted)
+	}
+
+	return r.bulkWrite(ctx, len(models), opts, prepare, complete)
+}
+
+// BulkSoftDelete soft deletes every document by ID in chunks
+// Documents that do not exist or are already deleted are skipped; compare
+// Matched with the number of IDs to detect them
+// As in SoftDelete, documents are loaded first when the model implements a
+// soft delete hook, and history is recorded when enabled
+func (r *Repository[T, PT]) BulkSoftDelete(ctx context.Context, ids []string, opts ...BulkOption) (*BulkResult, error) {
+	if _, err := tenantCondition(ctx, PT(new(T))); err != nil {
+		return nil, err
+	}
+
+	var meta BaseCollection
+	meta.SetDeleteMeta()
+
+	set := bson.M{"deleted_at": meta.DeletedAt}
+	if actor, ok := ActorFromContext(ctx); ok && r.isAuditable() {
+		set["deleted_by"] = actor
+	}
+	update := bson.M{"$set": set}
+	if r.isVersioned() {
+		update["$inc"] = bson.M{"version": 1}
+	}
+
+	hooks := hasSoftDeleteHooks(PT(new(T)))
+	objIDs := make([]interface{}, len(ids))
+	befores := make([]bson.Raw, len(ids))
+	loaded := make([]PT, len(ids))
+
+	prepare := func(i int) (mongo.WriteModel, string, error) {
+		objID, err := PT(new(T)).parseID(ids[i])
+		if err != nil {
+			return nil, ids[i], err
+		}
+		objIDs[i] = objID
+		query, err := r.scopedFilter(ctx, scopeActive, bson.M{"_id": objID})
+		if err != nil {
+			return nil, ids[i], err
+		}
+
+		if hooks || r.history != nil {
+			if befores[i], err = r.current(ctx, query); err != nil {
+				return nil, ids[i], err
+			}
+		}
+		if hooks && befores[i] != nil {
+			model := PT(new(T))
+			if err := bson.Unmarshal(befores[i], model); err != nil {
+				return nil, ids[i], err
+			}
+			if err := afterFind(ctx, model); err != nil {
+				return nil, ids[i], err
+			}
+			if err := beforeSoftDelete(ctx, model); err != nil {
+				return nil, ids[i], err
+			}
+			setDeleteMeta(ctx, model)
+			loaded[i] = model
+		}
+
+		return mongo.NewUpdateOneModel().
+			SetFilter(query).
+			SetUpdate(update), ids[i], nil
+	}
+
+	// Documents missing before the write were skipped by it
+	complete := func(item *BulkItem) error {
+		if befores[item.Index] == nil {
+			return nil
+		}
+		if err := r.recordHistory(ctx, HistorySoftDelete, objIDs[item.Index], befores[item.Index], nil); err != nil {
+			return err
+		}
+		if loaded[item.Index] != nil {
+			return afterSoftDelete(ctx, loaded[item.Index])
+		}
+		return nil
+	}
+
+	return r.bulkWrite(ctx, len(ids), opts, prepare, complete)
+}
+
+// bulkWrite prepares n write models and sends them in chunks, mapping every
+// failure back to its input index
+// complete runs for every written item once its chunk was sent; its error is
+// reported on the item
+func (r *Repository[T, PT]) bulkWrite(ctx context.Context, n int, opts []BulkOption, prepare func(i int) (mongo.WriteModel, string, error), complete func(item *BulkItem) error) (*BulkResult, error) {
+	cfg := bulkConfig{ordered: true, chunkSize: DefaultBulkChunkSize}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	result := &BulkResult{Items: make([]BulkItem, n)}
+	for i := range result.Items {
+		result.Items[i].Index = i
+	}
+
+	var (
+		batch   []mongo.WriteModel
+		indexes []int
+	)
+
+	// flush sends the batch and reports whether processing may continue
+	flush := func() (bool, error) {
+		if len(batch) == 0 {
+			return true, nil
+		}
+		defer func() {
+			batch, indexes = batch[:0], indexes[:0]
+		}()
+
+		res, err := r.collection.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(cfg.ordered))
+		if res != nil {
+			result.Inserted += res.InsertedCount
+			result.Matched += res.MatchedCount
+			result.Modified += res.ModifiedCount
+			result.Upserted += res.UpsertedCount
+			for pos := range res.UpsertedIDs {
+				result.Items[indexes[pos]].Upserted = true
+			}
+		}
+
+		var bwe mongo.BulkWriteException
+		if err != nil && !errors.As(err, &bwe) {
+			return false, err
+		}
+
+		failedAt := len(batch)
+		for _, we := range bwe.WriteErrors {
+			item := &result.Items[indexes[we.Index]]
+			item.Err = translateWriteError(mongo.WriteException{WriteErrors: []mongo.WriteError{we.WriteError}})
+			if we.Index < failedAt {
+				failedAt = we.Index
+			}
+		}
+		if bwe.WriteConcernError != nil {
+			return false, err
+		}
+		if cfg.ordered && failedAt < len(batch) {
+			for _, idx := range indexes[failedAt+1:] {
+				result.Items[idx].Err = ErrBulkAborted
+			}
+		}
+
+		for _, idx := range indexes {
+			if item := &result.Items[idx]; item.Err == nil {
+				item.Err = complete(item)
+			}
+		}
+
+		return !cfg.ordered || failedAt == len(batch), nil
+	}
+
+	for i := 0; i < n; i++ {
+		model, id, err := prepare(i)
+		result.Items[i].ID = id
+		if err != nil {
+			result.Items[i].Err = err
+			if !cfg.ordered {
+				continue
+			}
+			if _, err := flush(); err != nil {
+				return result, err
+			}
+			abortFrom(result, i+1)
+			return result, result.failed()
+		}
+
+		batch = append(batch, model)
+		indexes = append(indexes, i)
+		if len(batch) >= cfg.chunkSize {
+			ok, err := flush()
+			if err != nil {
+				return result, err
+			}
+			if !ok {
+				abortFrom(result, i+1)
+				return result, result.failed()
+			}
+		}
+	}
+
+	if _, err := flush(); err != nil {
+		return result, err
+	}
+
+	return result, result.failed()
+}
+
+// abortFrom marks every item from start on that has no error as not attempted
+func abortFrom(result *BulkResult, start int) {
+	for i := start; i < len(result.Items); i++ {
+		if result.Items[i].Err == nil {
+			result.Items[i].Err = ErrBulkAborted
+		}
+	}
+}
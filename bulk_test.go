@@ -0,0 +1,314 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// newUsers returns n users with distinct names
+func newUsers(n int) []*TestUser {
+	users := make([]*TestUser, n)
+	for i := range users {
+		users[i] = &TestUser{Name: string(rune('A' + i)), Email: string(rune('a'+i)) + "@example.com"}
+	}
+	return users
+}
+
+func TestRepositoryInsertMany(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("sets metadata and chunks", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		users := newUsers(3)
+		result, err := repo.InsertMany(context.Background(), users, WithChunkSize(2))
+		if err != nil {
+			mt.Fatalf("InsertMany returned error: %v", err)
+		}
+		if result.Inserted != 3 {
+			mt.Errorf("Expected 3 inserted, got %d", result.Inserted)
+		}
+		for i, u := range users {
+			if u.Oid.IsZero() || u.CreatedAt.IsZero() {
+				mt.Errorf("Expected insert metadata on user %d", i)
+			}
+			if result.Items[i].ID != u.GetID() {
+				mt.Errorf("Expected item %d to carry ID %s, got %s", i, u.GetID(), result.Items[i].ID)
+			}
+		}
+
+		for _, size := range []int{2, 1} {
+			event := mt.GetStartedEvent()
+			if event.CommandName != "insert" {
+				mt.Fatalf("Expected an insert command, got %s", event.CommandName)
+			}
+			docs, _ := event.Command.Lookup("documents").Array().Values()
+			if len(docs) != size {
+				mt.Errorf("Expected a chunk of %d documents, got %d", size, len(docs))
+			}
+		}
+	})
+
+	mt.Run("ordered stops at the first write error", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   1,
+			Code:    11000,
+			Message: `E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "b@example.com" }`,
+		}))
+
+		result, err := repo.InsertMany(context.Background(), newUsers(3))
+
+		var errs BulkErrors
+		if !errors.As(err, &errs) || len(errs) != 2 {
+			mt.Fatalf("Expected BulkErrors for 2 documents, got %v", err)
+		}
+		if result.Items[0].Err != nil {
+			mt.Errorf("Expected item 0 to succeed, got %v", result.Items[0].Err)
+		}
+		var dup *DuplicateKeyError
+		if !errors.As(result.Items[1].Err, &dup) || dup.Field != "email" {
+			mt.Errorf("Expected item 1 to fail with a duplicate email, got %v", result.Items[1].Err)
+		}
+		if !errors.Is(result.Items[2].Err, ErrBulkAborted) {
+			mt.Errorf("Expected item 2 to be aborted, got %v", result.Items[2].Err)
+		}
+	})
+
+	mt.Run("unordered continues past invalid documents", func(mt *mtest.T) {
+		repo := NewRepository[TestSignup](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}))
+
+		signups := []*TestSignup{
+			{Username: "x"},
+			{Username: "alice", Email: "alice@example.com"},
+			{Username: "bobby", Email: "bob@example.com"},
+		}
+		result, err := repo.InsertMany(context.Background(), signups, Unordered())
+
+		var errs BulkErrors
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Index != 0 {
+			mt.Fatalf("Expected BulkErrors for index 0, got %v", err)
+		}
+		var verrs ValidationErrors
+		if !errors.As(result.Items[0].Err, &verrs) {
+			mt.Errorf("Expected item 0 to fail validation, got %v", result.Items[0].Err)
+		}
+		if result.Inserted != 2 {
+			mt.Errorf("Expected 2 inserted, got %d", result.Inserted)
+		}
+
+		event := mt.GetStartedEvent()
+		if ordered, ok := event.Command.Lookup("ordered").BooleanOK(); !ok || ordered {
+			mt.Error("Expected the insert to be sent unordered")
+		}
+	})
+}
+
+func TestRepositoryBulkUpsert(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("sets on insert only the creation fields", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "n", Value: 2},
+			bson.E{Key: "nModified", Value: 1},
+			bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 1}, {Key: "_id", Value: primitive.NewObjectID()}}}},
+		))
+
+		users := newUsers(2)
+		result, err := repo.BulkUpsert(context.Background(), users, func(u *TestUser) interface{} {
+			return bson.M{"email": u.Email}
+		})
+		if err != nil {
+			mt.Fatalf("BulkUpsert returned error: %v", err)
+		}
+		if result.Items[0].Upserted || !result.Items[1].Upserted {
+			mt.Errorf("Expected only item 1 to be upserted, got %+v", result.Items)
+		}
+		if result.Upserted != 1 || result.Modified != 1 {
+			mt.Errorf("Expected 1 upserted and 1 modified, got %d and %d", result.Upserted, result.Modified)
+		}
+
+		updates, _ := mt.GetStartedEvent().Command.Lookup("updates").Array().Values()
+		stmt := updates[0].Document()
+		if !stmt.Lookup("upsert").Boolean() {
+			mt.Error("Expected upsert to be true")
+		}
+		update := stmt.Lookup("u").Document()
+		if _, err := update.LookupErr("$setOnInsert", "created_at"); err != nil {
+			mt.Error("Expected created_at in $setOnInsert")
+		}
+		if _, err := update.LookupErr("$setOnInsert", "_id"); err != nil {
+			mt.Error("Expected _id in $setOnInsert for a custom filter")
+		}
+		if _, err := update.LookupErr("$set", "created_at"); err == nil {
+			mt.Error("Expected created_at not to be $set")
+		}
+		if _, err := update.LookupErr("$set", "updated_at"); err != nil {
+			mt.Error("Expected updated_at to be $set")
+		}
+	})
+}
+
+func TestRepositoryBulkSoftDelete(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("reports invalid ids by index", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		ids := []string{primitive.NewObjectID().Hex(), "bad", primitive.NewObjectID().Hex()}
+		result, err := repo.BulkSoftDelete(context.Background(), ids, Unordered())
+
+		var errs BulkErrors
+		if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Index != 1 {
+			mt.Fatalf("Expected BulkErrors for index 1, got %v", err)
+		}
+		if !errors.Is(result.Items[1].Err, ErrInvalidID) {
+			mt.Errorf("Expected ErrInvalidID, got %v", result.Items[1].Err)
+		}
+		if result.Modified != 2 {
+			mt.Errorf("Expected 2 modified, got %d", result.Modified)
+		}
+
+		updates, _ := mt.GetStartedEvent().Command.Lookup("updates").Array().Values()
+		if len(updates) != 2 {
+			mt.Fatalf("Expected 2 updates, got %d", len(updates))
+		}
+		stmt := updates[0].Document()
+		if _, err := stmt.LookupErr("u", "$set", "deleted_at"); err != nil {
+			mt.Error("Expected deleted_at to be $set")
+		}
+		if _, ok := scopePredicate(stmt.Lookup("q").Document()); !ok {
+			mt.Error("Expected the active scope on the filter")
+		}
+	})
+}
+
+// startedCommands drains the started events as "command collection" pairs,
+// or bare command names for commands such as commitTransaction that do not
+// target a collection
+func startedCommands(mt *mtest.T) []string {
+	var commands []string
+	for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+		if coll, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+			commands = append(commands, e.CommandName+" "+coll)
+			continue
+		}
+		commands = append(commands, e.CommandName)
+	}
+	return commands
+}
+
+func TestRepositoryBulkHooksAndHistory(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("insert many runs hooks per document", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		members := []*TestMember{
+			{Email: "John@Example.com"},
+			{Email: "jane@example.com", Reject: "BeforeInsert"},
+		}
+		result, err := repo.InsertMany(context.Background(), members, Unordered())
+
+		var errs BulkErrors
+		if !errors.As(err, &errs) || len(errs) != 1 || !errors.Is(result.Items[1].Err, errHookRejected) {
+			mt.Fatalf("Expected the rejected member to fail, got %v", err)
+		}
+		if got := strings.Join(members[0].Calls, ","); got != "BeforeInsert,AfterInsert" {
+			mt.Errorf("Unexpected hook order: %s", got)
+		}
+
+		sent := mt.GetStartedEvent().Command.Lookup("documents").Array()
+		if sent.Index(0).Value().Document().Lookup("email").StringValue() != "john@example.com" {
+			mt.Error("Expected BeforeInsert to normalize the sent email")
+		}
+		if values, _ := sent.Values(); len(values) != 1 {
+			mt.Errorf("Expected only the accepted member to be sent, got %d", len(values))
+		}
+	})
+
+	mt.Run("insert many records history", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll, WithHistory())
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if _, err := repo.InsertMany(context.Background(), newUsers(2)); err != nil {
+			mt.Fatalf("InsertMany returned error: %v", err)
+		}
+
+		history := mt.Coll.Name() + "_history"
+		expected := []string{"insert " + mt.Coll.Name(), "insert " + history, "insert " + history}
+		if got := startedCommands(mt); strings.Join(got, ",") != strings.Join(expected, ",") {
+			mt.Errorf("Expected commands %v, got %v", expected, got)
+		}
+	})
+
+	mt.Run("bulk upsert picks the hooks by match", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+		existing := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch, bson.D{{Key: "_id", Value: existing}, {Key: "email", Value: "john@example.com"}}),
+			mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(
+				bson.E{Key: "n", Value: 2},
+				bson.E{Key: "nModified", Value: 1},
+				bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 1}, {Key: "_id", Value: primitive.NewObjectID()}}}},
+			),
+		)
+
+		members := []*TestMember{{Email: "john@example.com"}, {Email: "jane@example.com"}}
+		_, err := repo.BulkUpsert(context.Background(), members, func(m *TestMember) interface{} {
+			return bson.M{"email": m.Email}
+		})
+		if err != nil {
+			mt.Fatalf("BulkUpsert returned error: %v", err)
+		}
+		if got := strings.Join(members[0].Calls, ","); got != "BeforeUpdate,AfterUpdate" {
+			mt.Errorf("Expected update hooks for the matched member, got %s", got)
+		}
+		if got := strings.Join(members[1].Calls, ","); got != "BeforeInsert,AfterInsert" {
+			mt.Errorf("Expected insert hooks for the new member, got %s", got)
+		}
+	})
+
+	mt.Run("bulk soft delete records history for deleted documents", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll, WithHistory())
+		id := primitive.NewObjectID()
+		deleted := append(userDoc(id, "john"), bson.E{Key: "deleted_at", Value: primitive.NewDateTimeFromTime(timeNow())})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(id, "john")),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, deleted),
+			mtest.CreateSuccessResponse(),
+		)
+
+		ids := []string{id.Hex(), primitive.NewObjectID().Hex()}
+		if _, err := repo.BulkSoftDelete(context.Background(), ids); err != nil {
+			mt.Fatalf("BulkSoftDelete returned error: %v", err)
+		}
+
+		coll := mt.Coll.Name()
+		expected := []string{"find " + coll, "find " + coll, "update " + coll, "find " + coll, "insert " + coll + "_history"}
+		if got := startedCommands(mt); strings.Join(got, ",") != strings.Join(expected, ",") {
+			mt.Errorf("Expected commands %v, got %v", expected, got)
+		}
+	})
+}
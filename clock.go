@@ -0,0 +1,108 @@
+package basemodel
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the current time for metadata timestamps
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock backed by time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var (
+	timeMu        sync.RWMutex
+	clock         Clock = systemClock{}
+	timePrecision time.Duration
+	timeUTC       bool
+)
+
+// SetClock replaces the clock used by the Set*Meta methods and repositories
+// Passing nil restores the system clock
+func SetClock(c Clock) {
+	timeMu.Lock()
+	defer timeMu.Unlock()
+
+	if c == nil {
+		c = systemClock{}
+	}
+	clock = c
+}
+
+// SetTimePrecision truncates every generated timestamp to the given precision
+// Use time.Millisecond to match what MongoDB stores, so that timestamps
+// compare equal after a round trip. Zero disables truncation (default)
+func SetTimePrecision(d time.Duration) {
+	timeMu.Lock()
+	defer timeMu.Unlock()
+
+	timePrecision = d
+}
+
+// SetUTC controls whether generated timestamps are normalized to UTC
+func SetUTC(enabled bool) {
+	timeMu.Lock()
+	defer timeMu.Unlock()
+
+	timeUTC = enabled
+}
+
+// timeNow returns the current time from the configured clock,
+// normalized according to the precision and UTC settings
+func timeNow() time.Time {
+	timeMu.RLock()
+	defer timeMu.RUnlock()
+
+	t := clock.Now()
+	if timeUTC {
+		t = t.UTC()
+	}
+	if timePrecision > 0 {
+		t = t.Truncate(timePrecision)
+	}
+
+	return t
+}
+
+// FakeClock is a Clock that only moves when told to
+// It is intended for tests that need deterministic timestamps
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock creates a fake clock frozen at the given time
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// Now returns the frozen time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Set moves the clock to the given time
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = t
+}
+
+// Advance moves the clock forward by the given duration
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
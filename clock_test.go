@@ -0,0 +1,100 @@
+package basemodel
+
+import (
+	"testing"
+	"time"
+)
+
+// useFakeClock installs a fake clock for the duration of the test
+func useFakeClock(t *testing.T) *FakeClock {
+	t.Helper()
+
+	clock := NewFakeClock(time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC))
+	SetClock(clock)
+	t.Cleanup(func() {
+		SetClock(nil)
+	})
+
+	return clock
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	if !clock.Now().Equal(start) {
+		t.Errorf("Expected Now to return %v, got %v", start, clock.Now())
+	}
+
+	clock.Advance(time.Hour)
+	if !clock.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected Advance to move the clock by one hour, got %v", clock.Now())
+	}
+
+	later := start.Add(24 * time.Hour)
+	clock.Set(later)
+	if !clock.Now().Equal(later) {
+		t.Errorf("Expected Set to move the clock to %v, got %v", later, clock.Now())
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	clock := useFakeClock(t)
+
+	user := &TestUser{Name: "John Doe"}
+	user.SetInsertMeta()
+	if !user.CreatedAt.Equal(clock.Now()) {
+		t.Errorf("Expected CreatedAt to be %v, got %v", clock.Now(), user.CreatedAt)
+	}
+
+	clock.Advance(time.Minute)
+	user.SetDeleteMeta()
+	if !user.DeletedAt.Equal(clock.Now()) {
+		t.Errorf("Expected DeletedAt to be %v, got %v", clock.Now(), *user.DeletedAt)
+	}
+
+	SetClock(nil)
+	user.SetUpdateMeta()
+	if time.Since(*user.UpdatedAt) > time.Second {
+		t.Error("Expected SetClock(nil) to restore the system clock")
+	}
+}
+
+func TestSetTimePrecision(t *testing.T) {
+	clock := useFakeClock(t)
+	clock.Set(time.Date(2024, 5, 30, 9, 0, 0, 123456789, time.UTC))
+
+	SetTimePrecision(time.Millisecond)
+	t.Cleanup(func() {
+		SetTimePrecision(0)
+	})
+
+	user := &TestUser{Name: "John Doe"}
+	user.SetInsertMeta()
+
+	expected := time.Date(2024, 5, 30, 9, 0, 0, 123000000, time.UTC)
+	if !user.CreatedAt.Equal(expected) {
+		t.Errorf("Expected CreatedAt to be truncated to %v, got %v", expected, user.CreatedAt)
+	}
+}
+
+func TestSetUTC(t *testing.T) {
+	clock := useFakeClock(t)
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	clock.Set(time.Date(2024, 5, 30, 16, 0, 0, 0, bangkok))
+
+	SetUTC(true)
+	t.Cleanup(func() {
+		SetUTC(false)
+	})
+
+	user := &TestUser{Name: "John Doe"}
+	user.SetInsertMeta()
+
+	if user.CreatedAt.Location() != time.UTC {
+		t.Errorf("Expected CreatedAt in UTC, got %v", user.CreatedAt.Location())
+	}
+	if user.CreatedAt.Hour() != 9 {
+		t.Errorf("Expected CreatedAt hour 9 in UTC, got %d", user.CreatedAt.Hour())
+	}
+}
@@ -0,0 +1,134 @@
+package basemodel
+
+import (
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// ErrNotFound is returned when no document matches the requested ID or filter
+	ErrNotFound = errors.New("basemodel: document not found")
+
+	// ErrInvalidID is returned when an ID string cannot be parsed into the
+	// model's ID type, such as an invalid ObjectID hex
+	ErrInvalidID = errors.New("basemodel: invalid document id")
+
+	// ErrMissingID is returned when a model with a natural key is created
+	// without setting its ID
+	ErrMissingID = errors.New("basemodel: document id is not set")
+
+	// ErrNotDeleted is returned when restoring a document that was never soft deleted
+	ErrNotDeleted = errors.New("basemodel: document is not deleted")
+
+	// ErrVersionConflict is returned when a versioned document was modified
+	// by someone else since it was loaded
+	ErrVersionConflict = errors.New("basemodel: version conflict")
+
+	// ErrHistoryDisabled is returned when reading history from a repository
+	// created without WithHistory
+	ErrHistoryDisabled = errors.New("basemodel: history is not enabled")
+
+	// ErrInvalidPageToken is returned when a continuation token cannot be decoded
+	ErrInvalidPageToken = errors.New("basemodel: invalid page token")
+
+	// ErrMigrationLocked is returned when another instance holds the migration lock
+	ErrMigrationLocked = errors.New("basemodel: migrations are locked by another instance")
+
+	// ErrBulkAborted marks documents of an ordered bulk operation that were
+	// not attempted because an earlier document failed
+	ErrBulkAborted = errors.New("basemodel: not attempted after an earlier failure")
+
+	// ErrNoTenant is returned when a tenant model is accessed with a context
+	// that carries no tenant
+	ErrNoTenant = errors.New("basemodel: no tenant in context")
+
+	// ErrTenantUpdate is returned when an update of a tenant model would
+	// change its tenant_id
+	ErrTenantUpdate = errors.New("basemodel: tenant_id cannot be updated")
+)
+
+// ErrDuplicateKey matches every DuplicateKeyError with errors.Is
+var ErrDuplicateKey = errors.New("basemodel: duplicate key")
+
+// DuplicateKeyError is returned when a write breaks a unique index
+// Field names the conflicting field; compound indexes list every field
+// separated by commas
+type DuplicateKeyError struct {
+	Field string
+	Index string
+	Err   error
+}
+
+// Error implements the error interface
+func (e *DuplicateKeyError) Error() string {
+	if e.Field == "" {
+		return "basemodel: duplicate key on index " + e.Index
+	}
+	return "basemodel: duplicate value for " + e.Field
+}
+
+// Is reports whether target is ErrDuplicateKey
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+// Unwrap returns the original driver error
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.Err
+}
+
+// translateWriteError converts a driver duplicate key error into a
+// DuplicateKeyError and returns any other error unchanged
+func translateWriteError(err error) error {
+	if err == nil || !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	dup := &DuplicateKeyError{Err: err}
+	message := err.Error()
+
+	var we mongo.WriteException
+	if errors.As(err, &we) {
+		for _, e := range we.WriteErrors {
+			if mongo.IsDuplicateKeyError(mongo.WriteException{WriteErrors: []mongo.WriteError{e}}) {
+				message = e.Message
+				dup.Field = keyPatternFields(e.Raw)
+				break
+			}
+		}
+	}
+
+	if _, rest, ok := strings.Cut(message, "index: "); ok {
+		dup.Index, _, _ = strings.Cut(rest, " ")
+	}
+	if dup.Field == "" {
+		if _, rest, ok := strings.Cut(message, "dup key: { "); ok {
+			if field, _, ok := strings.Cut(rest, ":"); ok {
+				dup.Field = strings.Trim(field, `"`)
+			}
+		}
+	}
+
+	return dup
+}
+
+// keyPatternFields returns the fields of the keyPattern reported by the server
+func keyPatternFields(raw bson.Raw) string {
+	pattern, ok := raw.Lookup("keyPattern").DocumentOK()
+	if !ok {
+		return ""
+	}
+	elems, err := pattern.Elements()
+	if err != nil {
+		return ""
+	}
+
+	fields := make([]string, len(elems))
+	for i, e := range elems {
+		fields[i] = e.Key()
+	}
+	return strings.Join(fields, ",")
+}
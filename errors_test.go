@@ -0,0 +1,44 @@
+package basemodel
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTranslateWriteError(t *testing.T) {
+	t.Run("uses the key pattern", func(t *testing.T) {
+		raw, _ := bson.Marshal(bson.D{
+			{Key: "code", Value: 11000},
+			{Key: "keyPattern", Value: bson.D{{Key: "tenant_id", Value: 1}, {Key: "email", Value: 1}}},
+		})
+		err := translateWriteError(mongo.WriteException{WriteErrors: []mongo.WriteError{{
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: db.users index: tenant_email dup key: { tenant_id: \"t1\", email: \"a@b.c\" }",
+			Raw:     raw,
+		}}})
+
+		var dup *DuplicateKeyError
+		if !errors.As(err, &dup) {
+			t.Fatalf("Expected DuplicateKeyError, got %v", err)
+		}
+		if dup.Field != "tenant_id,email" {
+			t.Errorf("Expected fields tenant_id,email, got %q", dup.Field)
+		}
+		if dup.Index != "tenant_email" {
+			t.Errorf("Expected index tenant_email, got %q", dup.Index)
+		}
+	})
+
+	t.Run("leaves other errors unchanged", func(t *testing.T) {
+		original := mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 121, Message: "Document failed validation"}}}
+		if err := translateWriteError(original); errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("Expected a non duplicate key error to pass through, got %v", err)
+		}
+		if translateWriteError(nil) != nil {
+			t.Error("Expected nil to stay nil")
+		}
+	})
+}
@@ -25,7 +25,7 @@ type Product struct {
 }
 
 func main() {
-	fmt.Println("=== MongoDB BaseModel Example ===\n")
+	fmt.Print("=== MongoDB BaseModel Example ===\n\n")
 
 	// Example 1: Creating a new user
 	fmt.Println("1. Creating a new user:")
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	basemodel "github.com/thitipong-pu/mongo-basemodel"
+)
+
+func init() {
+	basemodel.RegisterMigration(basemodel.Migration{
+		Version:     20240601120000,
+		Description: "set is_active on existing users",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"is_active": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"is_active": true}})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"is_active": ""}})
+			return err
+		},
+	})
+
+	basemodel.RegisterMigration(basemodel.Migration{
+		Version:     20240615090000,
+		Description: "rename users.fullname to users.name",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"fullname": bson.M{"$exists": true}},
+				bson.M{"$rename": bson.M{"fullname": "name"}})
+			return err
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection("users").UpdateMany(ctx,
+				bson.M{"name": bson.M{"$exists": true}},
+				bson.M{"$rename": bson.M{"name": "fullname"}})
+			return err
+		},
+	})
+}
+
+// Usage:
+//
+//	go run ./examples/migrate status
+//	go run ./examples/migrate -dry-run up
+//	go run ./examples/migrate up
+//	go run ./examples/migrate down 1
+func main() {
+	ctx := context.Background()
+
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		uri = "mongodb://localhost:27017"
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer client.Disconnect(ctx)
+
+	migrator := basemodel.NewMigrator(client.Database("basemodel_example"))
+	if err := migrator.Run(ctx, os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -148,7 +148,7 @@ func (r *UserRepository) FindDeleted() ([]*User, error) {
 }
 
 func main() {
-	fmt.Println("=== MongoDB BaseModel Integration Example ===\n")
+	fmt.Print("=== MongoDB BaseModel Integration Example ===\n\n")
 
 	// Connect to MongoDB
 	// Note: Change the connection string to match your MongoDB setup
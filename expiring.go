@@ -0,0 +1,65 @@
+package basemodel
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ExpiryFields holds the time a document expires
+// Embed it inline next to BaseCollection or Base, together with any other
+// *Fields mixin. EnsureIndexes creates a TTL index on expires_at so MongoDB
+// removes expired documents, and repositories treat documents past their
+// expiry as absent because the TTL monitor only runs about once a minute
+// Documents without ExpiresAt never expire
+type ExpiryFields struct {
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+}
+
+// SetExpiry makes the document expire d from now
+func (e *ExpiryFields) SetExpiry(d time.Duration) {
+	expiresAt := timeNow().Add(d)
+	e.ExpiresAt = &expiresAt
+}
+
+// ClearExpiry makes the document never expire
+// Repository updates unset expires_at, so the TTL monitor no longer removes it
+func (e *ExpiryFields) ClearExpiry() {
+	e.ExpiresAt = nil
+}
+
+// IsExpired checks if the document is past its expiry time
+func (e *ExpiryFields) IsExpired() bool {
+	return e.ExpiresAt != nil && !timeNow().Before(*e.ExpiresAt)
+}
+
+// GetExpiresAt returns the expiry time, or nil when the document never expires
+func (e *ExpiryFields) GetExpiresAt() *time.Time {
+	return e.ExpiresAt
+}
+
+// ExpiringCollection extends BaseCollection with an expiry time
+// It is a shorthand for embedding BaseCollection and ExpiryFields
+type ExpiringCollection struct {
+	BaseCollection `bson:",inline"`
+	ExpiryFields   `bson:",inline"`
+}
+
+// expiring is implemented by models embedding ExpiryFields
+type expiring interface {
+	GetExpiresAt() *time.Time
+	IsExpired() bool
+}
+
+// expiryCondition returns the condition hiding expired documents of
+// expiring models, or nil for other models
+func expiryCondition(m Model) bson.D {
+	if _, ok := m.(expiring); !ok {
+		return nil
+	}
+
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "expires_at", Value: nil}},
+		bson.D{{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: timeNow()}}}},
+	}}}
+}
@@ -0,0 +1,167 @@
+package basemodel
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestInvitation is a test struct that embeds ExpiringCollection
+type TestInvitation struct {
+	ExpiringCollection `bson:",inline"`
+	Email              string `json:"email" bson:"email"`
+}
+
+func TestExpiringCollection(t *testing.T) {
+	clock := useFakeClock(t)
+
+	invite := &TestInvitation{Email: "john@example.com"}
+	if invite.IsExpired() {
+		t.Error("Expected a document without ExpiresAt never to expire")
+	}
+
+	invite.SetExpiry(time.Hour)
+	if !invite.GetExpiresAt().Equal(clock.Now().Add(time.Hour)) {
+		t.Errorf("Expected ExpiresAt one hour from now, got %v", invite.GetExpiresAt())
+	}
+	if invite.IsExpired() {
+		t.Error("Expected the document not to be expired yet")
+	}
+
+	clock.Advance(time.Hour)
+	if !invite.IsExpired() {
+		t.Error("Expected the document to be expired at ExpiresAt")
+	}
+
+	invite.ClearExpiry()
+	if invite.IsExpired() || invite.GetExpiresAt() != nil {
+		t.Error("Expected ClearExpiry to remove the expiry")
+	}
+}
+
+func TestExpiringIndexes(t *testing.T) {
+	specs, err := ModelIndexes(&TestInvitation{})
+	if err != nil {
+		t.Fatalf("ModelIndexes returned error: %v", err)
+	}
+
+	ttl, ok := indexNamed(specs, "expires_at_1")
+	if !ok || ttl.ExpireAfter == nil || *ttl.ExpireAfter != 0 {
+		t.Errorf("Expected a TTL index on expires_at expiring at the given time, got %+v", ttl)
+	}
+}
+
+func TestExpiringRepository(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("hides expired documents", func(mt *mtest.T) {
+		clock := useFakeClock(mt.T)
+		repo := NewRepository[TestInvitation](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.invitations", mtest.FirstBatch))
+
+		if _, err := repo.Find(context.Background(), bson.M{"email": "john@example.com"}); err != nil {
+			mt.Fatalf("Find returned error: %v", err)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		values, err := filter.Lookup("$and").Array().Values()
+		if err != nil || len(values) != 2 {
+			mt.Fatalf("Expected the filter to combine scope and expiry, got %s", filter)
+		}
+		or, err := values[1].Document().Lookup("$or").Array().Values()
+		if err != nil || len(or) != 2 {
+			mt.Fatalf("Expected an $or expiry condition, got %s", values[1])
+		}
+		gt := or[1].Document().Lookup("expires_at", "$gt").Time()
+		if !gt.Equal(clock.Now()) {
+			mt.Errorf("Expected expires_at > %v, got %v", clock.Now(), gt)
+		}
+	})
+
+	mt.Run("clearing the expiry unsets expires_at", func(mt *mtest.T) {
+		repo := NewRepository[TestInvitation](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		invitation := &TestInvitation{Email: "john@example.com"}
+		invitation.SetInsertMeta()
+		invitation.SetExpiry(time.Hour)
+		invitation.ClearExpiry()
+		if err := repo.Update(context.Background(), invitation); err != nil {
+			mt.Fatalf("Update returned error: %v", err)
+		}
+
+		stmt := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if _, err := stmt.LookupErr("u", "$unset", "expires_at"); err != nil {
+			mt.Errorf("Expected expires_at to be unset, got %s", stmt.Lookup("u"))
+		}
+	})
+
+	mt.Run("hard delete reaches expired documents", func(mt *mtest.T) {
+		repo := NewRepository[TestInvitation](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		if _, err := repo.HardDelete(context.Background(), primitive.NewObjectID().Hex()); err != nil {
+			mt.Fatalf("HardDelete returned error: %v", err)
+		}
+
+		stmt := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document()
+		if strings.Contains(stmt.Lookup("q").String(), "expires_at") {
+			mt.Errorf("Expected no expiry condition on a hard delete, got %s", stmt.Lookup("q"))
+		}
+	})
+}
+
+func TestExpiringMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	clock := useFakeClock(t)
+	repo := NewMemoryRepository[TestInvitation]()
+
+	invitation := &TestInvitation{Email: "john@example.com"}
+	invitation.SetExpiry(time.Hour)
+	if err := repo.Create(ctx, invitation); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	invitation.ClearExpiry()
+	if err := repo.Update(ctx, invitation); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	found, err := repo.FindByID(ctx, invitation.GetID())
+	if err != nil {
+		t.Fatalf("Expected a cleared expiry to keep the invitation, got %v", err)
+	}
+	if found.ExpiresAt != nil {
+		t.Errorf("Expected expires_at to be removed, got %v", found.ExpiresAt)
+	}
+}
+
+func TestExpiringMemoryRepositoryPurge(t *testing.T) {
+	ctx := context.Background()
+	clock := useFakeClock(t)
+	repo := NewMemoryRepository[TestInvitation]()
+
+	invitation := &TestInvitation{Email: "john@example.com"}
+	invitation.SetExpiry(time.Hour)
+	if err := repo.Create(ctx, invitation); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if err := repo.SoftDelete(ctx, invitation.GetID()); err != nil {
+		t.Fatalf("SoftDelete returned error: %v", err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	purged, err := repo.Purge(ctx, time.Hour)
+	if err != nil {
+		t.Fatalf("Purge returned error: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected Purge to remove the expired invitation, got %d", purged)
+	}
+}
@@ -0,0 +1,149 @@
+package basemodel
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field is a typed handle for a document field
+// Declare one per model field so typos are caught at compile time:
+//
+//	const FieldEmail basemodel.Field = "email"
+//
+//	filter := basemodel.And(FieldEmail.Eq("john@example.com"), basemodel.FieldDeletedAt.Exists(false))
+type Field string
+
+// Field handles for the BaseCollection fields
+const (
+	FieldID        Field = "_id"
+	FieldCreatedAt Field = "created_at"
+	FieldUpdatedAt Field = "updated_at"
+	FieldDeletedAt Field = "deleted_at"
+)
+
+// String returns the field name
+func (f Field) String() string {
+	return string(f)
+}
+
+// op builds {field: {operator: value}}
+func (f Field) op(operator string, value interface{}) bson.D {
+	return bson.D{{Key: string(f), Value: bson.D{{Key: operator, Value: value}}}}
+}
+
+// Eq matches documents where the field equals value
+func (f Field) Eq(value interface{}) bson.D {
+	return f.op("$eq", value)
+}
+
+// Ne matches documents where the field does not equal value
+func (f Field) Ne(value interface{}) bson.D {
+	return f.op("$ne", value)
+}
+
+// Gt matches documents where the field is greater than value
+func (f Field) Gt(value interface{}) bson.D {
+	return f.op("$gt", value)
+}
+
+// Gte matches documents where the field is greater than or equal to value
+func (f Field) Gte(value interface{}) bson.D {
+	return f.op("$gte", value)
+}
+
+// Lt matches documents where the field is less than value
+func (f Field) Lt(value interface{}) bson.D {
+	return f.op("$lt", value)
+}
+
+// Lte matches documents where the field is less than or equal to value
+func (f Field) Lte(value interface{}) bson.D {
+	return f.op("$lte", value)
+}
+
+// Range matches documents where the field is between from and to, inclusive
+func (f Field) Range(from, to interface{}) bson.D {
+	return bson.D{{Key: string(f), Value: bson.D{
+		{Key: "$gte", Value: from},
+		{Key: "$lte", Value: to},
+	}}}
+}
+
+// In matches documents where the field equals any of the values
+func (f Field) In(values ...interface{}) bson.D {
+	return f.op("$in", bson.A(values))
+}
+
+// Nin matches documents where the field equals none of the values
+func (f Field) Nin(values ...interface{}) bson.D {
+	return f.op("$nin", bson.A(values))
+}
+
+// Exists matches documents where the field is present (true) or missing (false)
+func (f Field) Exists(exists bool) bson.D {
+	return f.op("$exists", exists)
+}
+
+// Regex matches documents where the field matches the pattern
+// Options are the MongoDB regex flags, for example "i" for case-insensitive
+func (f Field) Regex(pattern, options string) bson.D {
+	return f.op("$regex", primitive.Regex{Pattern: pattern, Options: options})
+}
+
+// Asc sorts by the field in ascending order
+func (f Field) Asc() bson.E {
+	return bson.E{Key: string(f), Value: 1}
+}
+
+// Desc sorts by the field in descending order
+func (f Field) Desc() bson.E {
+	return bson.E{Key: string(f), Value: -1}
+}
+
+// And matches documents that satisfy every filter
+func And(filters ...bson.D) bson.D {
+	switch len(filters) {
+	case 0:
+		return bson.D{}
+	case 1:
+		return filters[0]
+	}
+	return bson.D{{Key: "$and", Value: toArray(filters)}}
+}
+
+// Or matches documents that satisfy at least one filter
+// With no filters it matches nothing, so an empty dynamic list never selects
+// the whole collection
+func Or(filters ...bson.D) bson.D {
+	switch len(filters) {
+	case 0:
+		return FieldID.op("$in", bson.A{})
+	case 1:
+		return filters[0]
+	}
+	return bson.D{{Key: "$or", Value: toArray(filters)}}
+}
+
+// Sort builds a sort document from Field.Asc and Field.Desc keys
+//
+//	opts := options.Find().SetSort(basemodel.Sort(basemodel.FieldCreatedAt.Desc(), basemodel.FieldID.Desc()))
+func Sort(keys ...bson.E) bson.D {
+	return bson.D(keys)
+}
+
+// Project builds a projection that includes only the given fields
+func Project(fields ...Field) bson.D {
+	projection := make(bson.D, len(fields))
+	for i, f := range fields {
+		projection[i] = bson.E{Key: string(f), Value: 1}
+	}
+	return projection
+}
+
+func toArray(filters []bson.D) bson.A {
+	arr := make(bson.A, len(filters))
+	for i, f := range filters {
+		arr[i] = f
+	}
+	return arr
+}
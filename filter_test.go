@@ -0,0 +1,91 @@
+package basemodel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	testFieldName  Field = "name"
+	testFieldEmail Field = "email"
+)
+
+func TestFieldOperators(t *testing.T) {
+	since := time.Date(2024, 5, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		got      bson.D
+		expected bson.D
+	}{
+		{"Eq", testFieldName.Eq("john"), bson.D{{Key: "name", Value: bson.D{{Key: "$eq", Value: "john"}}}}},
+		{"Ne", testFieldName.Ne("john"), bson.D{{Key: "name", Value: bson.D{{Key: "$ne", Value: "john"}}}}},
+		{"Gt", FieldCreatedAt.Gt(since), bson.D{{Key: "created_at", Value: bson.D{{Key: "$gt", Value: since}}}}},
+		{"Gte", FieldCreatedAt.Gte(since), bson.D{{Key: "created_at", Value: bson.D{{Key: "$gte", Value: since}}}}},
+		{"Lt", FieldCreatedAt.Lt(since), bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: since}}}}},
+		{"Lte", FieldCreatedAt.Lte(since), bson.D{{Key: "created_at", Value: bson.D{{Key: "$lte", Value: since}}}}},
+		{"Range", testFieldName.Range("a", "m"), bson.D{{Key: "name", Value: bson.D{{Key: "$gte", Value: "a"}, {Key: "$lte", Value: "m"}}}}},
+		{"In", testFieldName.In("a", "b"), bson.D{{Key: "name", Value: bson.D{{Key: "$in", Value: bson.A{"a", "b"}}}}}},
+		{"Nin", testFieldName.Nin("a"), bson.D{{Key: "name", Value: bson.D{{Key: "$nin", Value: bson.A{"a"}}}}}},
+		{"Exists", FieldDeletedAt.Exists(false), bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$exists", Value: false}}}}},
+		{"Regex", testFieldEmail.Regex("@example\\.com$", "i"), bson.D{{Key: "email", Value: bson.D{{Key: "$regex", Value: primitive.Regex{Pattern: "@example\\.com$", Options: "i"}}}}}},
+	}
+
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestAndOr(t *testing.T) {
+	a := testFieldName.Eq("john")
+	b := testFieldEmail.Eq("john@example.com")
+
+	if got := And(); !reflect.DeepEqual(got, bson.D{}) {
+		t.Errorf("Expected empty And to match everything, got %v", got)
+	}
+	if got := And(a); !reflect.DeepEqual(got, a) {
+		t.Errorf("Expected single And to return the filter, got %v", got)
+	}
+	if got := And(a, b); !reflect.DeepEqual(got, bson.D{{Key: "$and", Value: bson.A{a, b}}}) {
+		t.Errorf("Unexpected And: %v", got)
+	}
+	if got := Or(a, b); !reflect.DeepEqual(got, bson.D{{Key: "$or", Value: bson.A{a, b}}}) {
+		t.Errorf("Unexpected Or: %v", got)
+	}
+
+	empty := Or()
+	if expected := (bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: bson.A{}}}}}); !reflect.DeepEqual(empty, expected) {
+		t.Errorf("Expected empty Or to match no _id, got %v", empty)
+	}
+	doc := bson.D{{Key: "_id", Value: "a"}, {Key: "name", Value: "john"}}
+	if matched, err := matchDocument(doc, empty); err != nil || matched {
+		t.Errorf("Expected empty Or to match nothing, got %v (%v)", matched, err)
+	}
+}
+
+func TestSortAndProject(t *testing.T) {
+	sort := Sort(FieldCreatedAt.Desc(), FieldID.Asc())
+	expected := bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}
+	if !reflect.DeepEqual(sort, expected) {
+		t.Errorf("Expected %v, got %v", expected, sort)
+	}
+
+	projection := Project(testFieldName, FieldCreatedAt)
+	expected = bson.D{{Key: "name", Value: 1}, {Key: "created_at", Value: 1}}
+	if !reflect.DeepEqual(projection, expected) {
+		t.Errorf("Expected %v, got %v", expected, projection)
+	}
+}
+
+func TestFilterMarshals(t *testing.T) {
+	filter := And(testFieldName.In("a", "b"), FieldDeletedAt.Exists(false))
+	if _, err := bson.Marshal(filter); err != nil {
+		t.Errorf("Expected filter to be driver-ready, got %v", err)
+	}
+}
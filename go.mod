@@ -7,6 +7,7 @@ toolchain go1.24.3
 require go.mongodb.org/mongo-driver v1.17.3
 
 require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/golang/snappy v1.0.0 // indirect
 	github.com/klauspost/compress v1.18.0 // indirect
 	github.com/montanaflynn/stats v0.7.1 // indirect
@@ -0,0 +1,207 @@
+package basemodel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HistoryOperation identifies the kind of change recorded in the history
+type HistoryOperation string
+
+const (
+	HistoryInsert     HistoryOperation = "insert"
+	HistoryUpdate     HistoryOperation = "update"
+	HistorySoftDelete HistoryOperation = "soft_delete"
+	HistoryRestore    HistoryOperation = "restore"
+)
+
+// HistoryEntry is a shadow record of a single change to a document
+// Before and After hold full snapshots; Before is empty for inserts
+type HistoryEntry struct {
+	Oid        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	DocumentID interface{}        `json:"document_id" bson:"document_id"`
+	Operation  HistoryOperation   `json:"operation" bson:"operation"`
+	Actor      string             `json:"actor,omitempty" bson:"actor,omitempty"`
+	Timestamp  time.Time          `json:"timestamp" bson:"timestamp"`
+	Before     bson.Raw           `json:"before,omitempty" bson:"before,omitempty"`
+	After      bson.Raw           `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// historyCollectionName returns the shadow collection name for a collection
+func historyCollectionName(collection string) string {
+	return collection + "_history"
+}
+
+// History returns the recorded changes of a document, oldest first
+// It returns ErrHistoryDisabled when the repository was created without WithHistory
+// For tenant models it returns ErrNotFound when the document belongs to another tenant
+func (r *Repository[T, PT]) History(ctx context.Context, id string) ([]HistoryEntry, error) {
+	if r.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// History entries carry no tenant, so check the document belongs to it
+	if _, ok := any(PT(new(T))).(tenantScoped); ok {
+		n, err := r.withScope(scopeWithDeleted).Count(ctx, bson.M{"_id": objID})
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrNotFound
+		}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.history.Find(ctx, bson.M{"document_id": objID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var entries []HistoryEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// snapshot reads the raw stored document when history is enabled
+func (r *Repository[T, PT]) snapshot(ctx context.Context, id interface{}) (bson.Raw, error) {
+	return snapshot(ctx, r.collection, r.history, id)
+}
+
+// recordHistory writes a history entry when history is enabled
+// The after snapshot is read from the collection unless provided
+func (r *Repository[T, PT]) recordHistory(ctx context.Context, op HistoryOperation, id interface{}, before, after bson.Raw) error {
+	return recordHistory(ctx, r.collection, r.history, op, id, before, after)
+}
+
+// snapshot reads the raw stored document from collection when a history
+// collection is given
+func snapshot(ctx context.Context, collection, history *mongo.Collection, id interface{}) (bson.Raw, error) {
+	if history == nil {
+		return nil, nil
+	}
+
+	raw, err := collection.FindOne(ctx, bson.M{"_id": id}).Raw()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+
+	return raw, err
+}
+
+// recordHistory writes a history entry of a document in collection to the
+// history collection, if any
+// The after snapshot is read from collection unless provided
+func recordHistory(ctx context.Context, collection, history *mongo.Collection, op HistoryOperation, id interface{}, before, after bson.Raw) error {
+	if history == nil {
+		return nil
+	}
+
+	if after == nil {
+		var err error
+		if after, err = snapshot(ctx, collection, history, id); err != nil {
+			return fmt.Errorf("basemodel: record history: %w", err)
+		}
+	}
+
+	entry := HistoryEntry{
+		Oid:        primitive.NewObjectID(),
+		DocumentID: id,
+		Operation:  op,
+		Timestamp:  timeNow(),
+		Before:     before,
+		After:      after,
+	}
+	entry.Actor, _ = ActorFromContext(ctx)
+
+	if _, err := history.InsertOne(ctx, entry); err != nil {
+		return fmt.Errorf("basemodel: record history: %w", err)
+	}
+
+	return nil
+}
+
+// updateManyWithHistory runs an UpdateMany restricted to the documents the
+// query matched beforehand and records an update entry for each one changed,
+// or an insert entry for an upserted document
+func (r *Repository[T, PT]) updateManyWithHistory(ctx context.Context, query, update interface{}, opts []*options.UpdateOptions) (int64, error) {
+	befores, err := r.rawDocuments(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make(bson.A, len(befores))
+	for i, before := range befores {
+		ids[i] = before.Lookup("_id")
+	}
+	if len(ids) > 0 {
+		query = bson.D{{Key: "$and", Value: bson.A{query, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}}}}
+	}
+
+	result, err := r.collection.UpdateMany(ctx, query, update, opts...)
+	if err != nil {
+		return 0, translateWriteError(err)
+	}
+	if result.UpsertedID != nil {
+		if err := r.recordHistory(ctx, HistoryInsert, result.UpsertedID, nil, nil); err != nil {
+			return result.ModifiedCount, err
+		}
+	}
+	if result.ModifiedCount == 0 {
+		return 0, nil
+	}
+
+	afters, err := r.rawDocuments(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+	if err != nil {
+		return result.ModifiedCount, fmt.Errorf("basemodel: record history: %w", err)
+	}
+	byID := make(map[string]bson.Raw, len(afters))
+	for _, after := range afters {
+		byID[after.Lookup("_id").String()] = after
+	}
+
+	for _, before := range befores {
+		id := before.Lookup("_id")
+		after, ok := byID[id.String()]
+		if !ok || bytes.Equal(before, after) {
+			continue
+		}
+		if err := r.recordHistory(ctx, HistoryUpdate, id, before, after); err != nil {
+			return result.ModifiedCount, err
+		}
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// rawDocuments reads every raw document matching the query
+func (r *Repository[T, PT]) rawDocuments(ctx context.Context, query interface{}) ([]bson.Raw, error) {
+	cursor, err := r.collection.Find(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.Raw
+	for cursor.Next(ctx) {
+		docs = append(docs, append(bson.Raw(nil), cursor.Current...))
+	}
+
+	return docs, cursor.Err()
+}
@@ -0,0 +1,153 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRepositoryHistory(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("create records insert", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll, WithHistory())
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		user := &TestUser{Name: "John Doe"}
+		ctx := WithActor(context.Background(), "alice")
+		if err := repo.Create(ctx, user); err != nil {
+			mt.Fatalf("Create returned error: %v", err)
+		}
+
+		mt.GetStartedEvent() // document insert
+		evt := mt.GetStartedEvent()
+		if coll := evt.Command.Lookup("insert").StringValue(); coll != mt.Coll.Name()+"_history" {
+			mt.Fatalf("Expected insert into history collection, got %s", coll)
+		}
+
+		entry := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if entry.Lookup("document_id").ObjectID() != user.Oid {
+			mt.Error("Expected history entry to reference the document")
+		}
+		if entry.Lookup("operation").StringValue() != string(HistoryInsert) {
+			mt.Errorf("Expected insert operation, got %s", entry.Lookup("operation"))
+		}
+		if entry.Lookup("actor").StringValue() != "alice" {
+			mt.Errorf("Expected actor alice, got %s", entry.Lookup("actor"))
+		}
+		if entry.Lookup("after", "name").StringValue() != "John Doe" {
+			mt.Errorf("Expected after snapshot, got %s", entry.Lookup("after"))
+		}
+	})
+
+	mt.Run("soft delete records before and after", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll, WithHistory())
+		id := primitive.NewObjectID()
+		deleted := append(userDoc(id, "john"), bson.E{Key: "deleted_at", Value: primitive.NewDateTimeFromTime(timeNow())})
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(id, "john")),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, deleted),
+			mtest.CreateSuccessResponse(),
+		)
+
+		if err := repo.SoftDelete(context.Background(), id.Hex()); err != nil {
+			mt.Fatalf("SoftDelete returned error: %v", err)
+		}
+
+		evt := mt.GetStartedEvent()
+		for evt != nil && evt.CommandName != "insert" {
+			evt = mt.GetStartedEvent()
+		}
+		if evt == nil {
+			mt.Fatal("Expected a history insert")
+		}
+
+		entry := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+		if entry.Lookup("operation").StringValue() != string(HistorySoftDelete) {
+			mt.Errorf("Expected soft_delete operation, got %s", entry.Lookup("operation"))
+		}
+		if _, err := entry.LookupErr("before", "deleted_at"); err == nil {
+			mt.Error("Expected before snapshot without deleted_at")
+		}
+		if _, err := entry.LookupErr("after", "deleted_at"); err != nil {
+			mt.Error("Expected after snapshot with deleted_at")
+		}
+	})
+
+	mt.Run("update many records each document", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll, WithHistory())
+		ids := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(ids[0], "john"), userDoc(ids[1], "jane")),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(ids[0], "JOHN"), userDoc(ids[1], "JANE")),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		modified, err := repo.UpdateMany(context.Background(), bson.M{}, bson.M{"$set": bson.M{"active": false}})
+		if err != nil || modified != 2 {
+			mt.Fatalf("Expected 2 modified documents, got %d (%v)", modified, err)
+		}
+
+		mt.GetStartedEvent() // find matched documents
+		stmt := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if _, err := stmt.LookupErr("q", "$and", "1", "_id", "$in"); err != nil {
+			mt.Errorf("Expected the update to be restricted to the matched IDs, got %s", stmt.Lookup("q"))
+		}
+		mt.GetStartedEvent() // find updated documents
+
+		for i, id := range ids {
+			evt := mt.GetStartedEvent()
+			if evt == nil || evt.CommandName != "insert" {
+				mt.Fatalf("Expected history insert %d, got %v", i, evt)
+			}
+			entry := evt.Command.Lookup("documents").Array().Index(0).Value().Document()
+			if entry.Lookup("document_id").ObjectID() != id {
+				mt.Errorf("Expected entry for %s, got %s", id.Hex(), entry.Lookup("document_id"))
+			}
+			if entry.Lookup("operation").StringValue() != string(HistoryUpdate) {
+				mt.Errorf("Expected update operation, got %s", entry.Lookup("operation"))
+			}
+			if entry.Lookup("before", "name").StringValue() == entry.Lookup("after", "name").StringValue() {
+				mt.Errorf("Expected before and after snapshots, got %s", entry)
+			}
+		}
+	})
+
+	mt.Run("lists history by document", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll, WithHistory())
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users_history", mtest.FirstBatch,
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "document_id", Value: id}, {Key: "operation", Value: "insert"}},
+			bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "document_id", Value: id}, {Key: "operation", Value: "update"}},
+		))
+
+		entries, err := repo.History(context.Background(), id.Hex())
+		if err != nil {
+			mt.Fatalf("History returned error: %v", err)
+		}
+		if len(entries) != 2 || entries[0].Operation != HistoryInsert || entries[1].Operation != HistoryUpdate {
+			mt.Errorf("Unexpected history entries: %+v", entries)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if filter.Lookup("document_id").ObjectID() != id {
+			mt.Errorf("Expected filter on document_id, got %s", filter)
+		}
+	})
+
+	mt.Run("disabled by default", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+
+		_, err := repo.History(context.Background(), primitive.NewObjectID().Hex())
+		if !errors.Is(err, ErrHistoryDisabled) {
+			mt.Errorf("Expected ErrHistoryDisabled, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package basemodel
+
+import "context"
+
+// Lifecycle hooks are optional interfaces a model can implement to run its
+// own logic around repository operations. Before hooks run before the
+// metadata setter and an error aborts the operation without writing.
+// After hooks run once the write succeeded; their error is returned to the
+// caller but the write is not rolled back.
+//
+// Insert: BeforeInsert, SetInsertMeta, write, AfterInsert
+// Update: BeforeUpdate, SetUpdateMeta, write, AfterUpdate
+// Soft delete: BeforeSoftDelete, SetDeleteMeta, write, AfterSoftDelete
+// Read: decode, AfterFind
+
+// BeforeInserter is called before a model is inserted
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// AfterInserter is called after a model was inserted
+type AfterInserter interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// BeforeUpdater is called before a model is updated
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// AfterUpdater is called after a model was updated
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+// BeforeSoftDeleter is called before a model is soft deleted
+type BeforeSoftDeleter interface {
+	BeforeSoftDelete(ctx context.Context) error
+}
+
+// AfterSoftDeleter is called after a model was soft deleted
+type AfterSoftDeleter interface {
+	AfterSoftDelete(ctx context.Context) error
+}
+
+// AfterFinder is called after a model was loaded from the database
+type AfterFinder interface {
+	AfterFind(ctx context.Context) error
+}
+
+func beforeInsert(ctx context.Context, m Model) error {
+	if h, ok := m.(BeforeInserter); ok {
+		return h.BeforeInsert(ctx)
+	}
+	return nil
+}
+
+func afterInsert(ctx context.Context, m Model) error {
+	if h, ok := m.(AfterInserter); ok {
+		return h.AfterInsert(ctx)
+	}
+	return nil
+}
+
+func beforeUpdate(ctx context.Context, m Model) error {
+	if h, ok := m.(BeforeUpdater); ok {
+		return h.BeforeUpdate(ctx)
+	}
+	return nil
+}
+
+func afterUpdate(ctx context.Context, m Model) error {
+	if h, ok := m.(AfterUpdater); ok {
+		return h.AfterUpdate(ctx)
+	}
+	return nil
+}
+
+func beforeSoftDelete(ctx context.Context, m Model) error {
+	if h, ok := m.(BeforeSoftDeleter); ok {
+		return h.BeforeSoftDelete(ctx)
+	}
+	return nil
+}
+
+func afterSoftDelete(ctx context.Context, m Model) error {
+	if h, ok := m.(AfterSoftDeleter); ok {
+		return h.AfterSoftDelete(ctx)
+	}
+	return nil
+}
+
+func afterFind(ctx context.Context, m Model) error {
+	if h, ok := m.(AfterFinder); ok {
+		return h.AfterFind(ctx)
+	}
+	return nil
+}
+
+// hasSoftDeleteHooks reports whether the model type implements a soft delete hook
+func hasSoftDeleteHooks(m Model) bool {
+	_, before := m.(BeforeSoftDeleter)
+	_, after := m.(AfterSoftDeleter)
+	return before || after
+}
+
+// hasUpsertHooks reports whether the model type implements an insert or
+// update before hook, which an upsert picks by whether a document matches
+func hasUpsertHooks(m Model) bool {
+	_, insert := m.(BeforeInserter)
+	_, update := m.(BeforeUpdater)
+	return insert || update
+}
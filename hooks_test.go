@@ -0,0 +1,172 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+var errHookRejected = errors.New("hook rejected")
+
+// TestMember is a test struct implementing every lifecycle hook
+type TestMember struct {
+	BaseCollection `bson:",inline"`
+	Email          string   `json:"email" bson:"email"`
+	Calls          []string `json:"-" bson:"-"`
+	Reject         string   `json:"-" bson:"-"`
+}
+
+func (m *TestMember) record(hook string) error {
+	m.Calls = append(m.Calls, hook)
+	if m.Reject == hook {
+		return errHookRejected
+	}
+	return nil
+}
+
+func (m *TestMember) BeforeInsert(ctx context.Context) error {
+	m.Email = strings.ToLower(m.Email)
+	if !m.CreatedAt.IsZero() {
+		m.Calls = append(m.Calls, "meta set too early")
+	}
+	return m.record("BeforeInsert")
+}
+
+func (m *TestMember) AfterInsert(ctx context.Context) error {
+	return m.record("AfterInsert")
+}
+
+func (m *TestMember) BeforeUpdate(ctx context.Context) error {
+	return m.record("BeforeUpdate")
+}
+
+func (m *TestMember) AfterUpdate(ctx context.Context) error {
+	return m.record("AfterUpdate")
+}
+
+func (m *TestMember) BeforeSoftDelete(ctx context.Context) error {
+	return m.record("BeforeSoftDelete")
+}
+
+func (m *TestMember) AfterSoftDelete(ctx context.Context) error {
+	return m.record("AfterSoftDelete")
+}
+
+func (m *TestMember) AfterFind(ctx context.Context) error {
+	return m.record("AfterFind")
+}
+
+func TestRepositoryInsertHooks(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("hooks run around the write", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		member := &TestMember{Email: "John@Example.com"}
+		if err := repo.Create(context.Background(), member); err != nil {
+			mt.Fatalf("Create returned error: %v", err)
+		}
+
+		if got := strings.Join(member.Calls, ","); got != "BeforeInsert,AfterInsert" {
+			mt.Errorf("Unexpected hook order: %s", got)
+		}
+
+		sent := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if sent.Lookup("email").StringValue() != "john@example.com" {
+			mt.Errorf("Expected BeforeInsert changes to be written, got %s", sent)
+		}
+	})
+
+	mt.Run("before hook error aborts the insert", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+
+		member := &TestMember{Email: "john@example.com", Reject: "BeforeInsert"}
+		err := repo.Create(context.Background(), member)
+		if !errors.Is(err, errHookRejected) {
+			mt.Errorf("Expected hook error, got %v", err)
+		}
+		if !member.Oid.IsZero() {
+			mt.Error("Expected insert metadata not to be set after an aborted insert")
+		}
+		if evt := mt.GetStartedEvent(); evt != nil {
+			mt.Errorf("Expected no command to be sent, got %s", evt.CommandName)
+		}
+	})
+}
+
+func TestRepositoryUpdateHooks(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("before hook error aborts the update", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+
+		member := &TestMember{Email: "john@example.com", Reject: "BeforeUpdate"}
+		member.SetInsertMeta()
+		err := repo.Update(context.Background(), member)
+		if !errors.Is(err, errHookRejected) {
+			mt.Errorf("Expected hook error, got %v", err)
+		}
+		if member.UpdatedAt != nil {
+			mt.Error("Expected update metadata not to be set after an aborted update")
+		}
+	})
+
+	mt.Run("after hook runs on success", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		member := &TestMember{Email: "john@example.com"}
+		member.SetInsertMeta()
+		if err := repo.Update(context.Background(), member); err != nil {
+			mt.Fatalf("Update returned error: %v", err)
+		}
+		if got := strings.Join(member.Calls, ","); got != "BeforeUpdate,AfterUpdate" {
+			mt.Errorf("Unexpected hook order: %s", got)
+		}
+	})
+}
+
+func TestRepositorySoftDeleteHooks(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("loads the document for the hooks", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+		member := &TestMember{Email: "john@example.com"}
+		member.SetInsertMeta()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: member.Oid},
+				{Key: "email", Value: member.Email},
+			}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		if err := repo.SoftDelete(context.Background(), member.GetID()); err != nil {
+			mt.Fatalf("SoftDelete returned error: %v", err)
+		}
+
+		if evt := mt.GetStartedEvent(); evt.CommandName != "find" {
+			mt.Errorf("Expected the document to be loaded first, got %s", evt.CommandName)
+		}
+	})
+
+	mt.Run("after find runs on every result", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch, bson.D{
+			{Key: "email", Value: "john@example.com"},
+		}))
+
+		members, err := repo.FindAll(context.Background())
+		if err != nil {
+			mt.Fatalf("FindAll returned error: %v", err)
+		}
+		if len(members) != 1 || strings.Join(members[0].Calls, ",") != "AfterFind" {
+			mt.Errorf("Expected AfterFind to run on every result, got %+v", members)
+		}
+	})
+}
@@ -0,0 +1,343 @@
+package basemodel
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// IDType is the constraint for the key type of Base
+// NewID is called on the zero value and returns a freshly generated key;
+// natural keys return the zero value so the caller has to set them
+// ParseID converts the string returned by String back into a key
+type IDType[K any] interface {
+	comparable
+	String() string
+	NewID() K
+	ParseID(s string) (K, error)
+}
+
+// identified is implemented by BaseCollection and Base; it gives the
+// repository the typed _id behind GetID
+type identified interface {
+	documentID() interface{}
+	hasID() bool
+	parseID(id string) (interface{}, error)
+}
+
+// Ensure Base implements Model
+var _ Model = (*Base[UUID])(nil)
+
+// Base provides the fields and methods of BaseCollection for models whose
+// _id is not an ObjectID
+//
+//	type Order struct {
+//		basemodel.Base[basemodel.UUID] `bson:",inline"`
+//		Total int64 `bson:"total"`
+//	}
+//
+// BaseCollection remains the ObjectID default. AuditFields, VersionFields,
+// TenantFields and ExpiryFields can be embedded next to either
+type Base[K IDType[K]] struct {
+	ID        K          `json:"_id" bson:"_id"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// SetInsertMeta sets the metadata for insert operations
+// It generates a new ID when none is set and sets the CreatedAt timestamp
+func (b *Base[K]) SetInsertMeta() {
+	var zero K
+	if b.ID == zero {
+		b.ID = zero.NewID()
+	}
+	b.CreatedAt = timeNow()
+}
+
+// SetUpdateMeta sets the metadata for update operations
+// It sets the UpdatedAt timestamp
+func (b *Base[K]) SetUpdateMeta() {
+	now := timeNow()
+	b.UpdatedAt = &now
+}
+
+// SetDeleteMeta sets the metadata for soft delete operations
+// It sets the DeletedAt timestamp for soft deletion
+func (b *Base[K]) SetDeleteMeta() {
+	now := timeNow()
+	b.DeletedAt = &now
+}
+
+// ClearDeleteMeta restores a soft deleted record
+// It clears the DeletedAt timestamp and sets the UpdatedAt timestamp
+func (b *Base[K]) ClearDeleteMeta() {
+	now := timeNow()
+	b.DeletedAt = nil
+	b.UpdatedAt = &now
+}
+
+// IsDeleted checks if the record is soft deleted
+func (b *Base[K]) IsDeleted() bool {
+	return b.DeletedAt != nil
+}
+
+// GetID returns the ID as a string
+func (b *Base[K]) GetID() string {
+	return b.ID.String()
+}
+
+// GetCreatedAt returns the creation timestamp
+func (b *Base[K]) GetCreatedAt() time.Time {
+	return b.CreatedAt
+}
+
+// GetUpdatedAt returns the last update timestamp
+func (b *Base[K]) GetUpdatedAt() *time.Time {
+	return b.UpdatedAt
+}
+
+// GetDeletedAt returns the deletion timestamp
+func (b *Base[K]) GetDeletedAt() *time.Time {
+	return b.DeletedAt
+}
+
+// documentID returns the typed ID for use in repository filters
+func (b *Base[K]) documentID() interface{} {
+	return b.ID
+}
+
+// hasID reports whether the ID is set
+func (b *Base[K]) hasID() bool {
+	var zero K
+	return b.ID != zero
+}
+
+// parseID converts the string form of an ID for use in repository filters
+func (b *Base[K]) parseID(id string) (interface{}, error) {
+	var zero K
+	key, err := zero.ParseID(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+	return key, nil
+}
+
+// requireID returns ErrMissingID when the model has no ID after the insert
+// metadata was applied, which happens for natural keys left empty
+func requireID(m Model) error {
+	if i, ok := m.(identified); ok && !i.hasID() {
+		return ErrMissingID
+	}
+	return nil
+}
+
+// UUID is an RFC 9562 UUID stored as BSON binary subtype 4
+// NewID generates version 7 UUIDs, which sort by creation time
+type UUID [16]byte
+
+// NewID returns a new version 7 UUID
+func (UUID) NewID() UUID {
+	var u UUID
+	binary.BigEndian.PutUint64(u[:8], uint64(timeNow().UnixMilli())<<16)
+	_, _ = rand.Read(u[6:])
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// String returns the canonical 36 character form
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// ParseID parses the canonical form of a UUID
+func (UUID) ParseID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, ErrInvalidID
+	}
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUID{}, ErrInvalidID
+	}
+	return u, nil
+}
+
+// MarshalText implements encoding.TextMarshaler for JSON
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for JSON
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := u.ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// MarshalBSONValue stores the UUID as binary subtype 4
+func (u UUID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bsontype.Binary, bsoncore.AppendBinary(nil, bsontype.BinaryUUID, u[:]), nil
+}
+
+// UnmarshalBSONValue reads a binary UUID, or its string form
+func (u *UUID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	switch t {
+	case bsontype.Null:
+		*u = UUID{}
+		return nil
+	case bsontype.String:
+		s, _, ok := bsoncore.ReadString(data)
+		if !ok {
+			return ErrInvalidID
+		}
+		return u.UnmarshalText([]byte(s))
+	case bsontype.Binary:
+		_, b, _, ok := bsoncore.ReadBinary(data)
+		if !ok || len(b) != len(u) {
+			return ErrInvalidID
+		}
+		copy(u[:], b)
+		return nil
+	}
+	return ErrInvalidID
+}
+
+// crockford is the Crockford base32 alphabet used by ULID
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID is a 128 bit identifier stored as its 26 character Crockford base32
+// string, which sorts by creation time
+// IDs generated within the same millisecond are not ordered between themselves
+type ULID [16]byte
+
+// NewID returns a new ULID with the current time and 80 random bits
+func (ULID) NewID() ULID {
+	var u ULID
+	binary.BigEndian.PutUint64(u[:8], uint64(timeNow().UnixMilli())<<16)
+	_, _ = rand.Read(u[6:])
+	return u
+}
+
+// String returns the 26 character Crockford base32 form
+func (u ULID) String() string {
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+
+	// 128 bits in 26 groups of 5, the first group holding only 3 bits
+	var buf [26]byte
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// ParseID parses the base32 form of a ULID, ignoring case
+func (ULID) ParseID(s string) (ULID, error) {
+	var u ULID
+	if len(s) != 26 {
+		return u, ErrInvalidID
+	}
+
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		v := -1
+		for j := 0; j < len(crockford); j++ {
+			if crockford[j] == c {
+				v = j
+				break
+			}
+		}
+		if v < 0 || (i == 0 && v > 7) {
+			return u, ErrInvalidID
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(v)
+	}
+
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, nil
+}
+
+// MarshalText implements encoding.TextMarshaler for JSON
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for JSON
+func (u *ULID) UnmarshalText(text []byte) error {
+	parsed, err := u.ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+// MarshalBSONValue stores the ULID as a string
+func (u ULID) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bsontype.String, bsoncore.AppendString(nil, u.String()), nil
+}
+
+// UnmarshalBSONValue reads the string form of a ULID
+func (u *ULID) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	switch t {
+	case bsontype.Null:
+		*u = ULID{}
+		return nil
+	case bsontype.String:
+		s, _, ok := bsoncore.ReadString(data)
+		if !ok {
+			return ErrInvalidID
+		}
+		return u.UnmarshalText([]byte(s))
+	}
+	return ErrInvalidID
+}
+
+// StringID is a natural key such as a SKU or a country code
+// NewID returns an empty key, so the ID must be set before Create
+type StringID string
+
+// NewID returns an empty key; natural keys are chosen by the caller
+func (StringID) NewID() StringID {
+	return ""
+}
+
+// String returns the key
+func (s StringID) String() string {
+	return string(s)
+}
+
+// ParseID accepts any non-empty string
+func (StringID) ParseID(s string) (StringID, error) {
+	if s == "" {
+		return "", ErrInvalidID
+	}
+	return StringID(s), nil
+}
@@ -0,0 +1,203 @@
+package basemodel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestOrder is a test struct keyed by a UUIDv7
+type TestOrder struct {
+	Base[UUID] `bson:",inline"`
+	Total      int64 `json:"total" bson:"total"`
+}
+
+// TestEvent is a test struct keyed by a ULID
+type TestEvent struct {
+	Base[ULID] `bson:",inline"`
+	Kind       string `json:"kind" bson:"kind"`
+}
+
+// TestCountry is a test struct keyed by a natural string key
+type TestCountry struct {
+	Base[StringID] `bson:",inline"`
+	Name           string `json:"name" bson:"name"`
+}
+
+func TestUUID(t *testing.T) {
+	clock := useFakeClock(t)
+
+	first := UUID{}.NewID()
+	clock.Advance(time.Millisecond)
+	second := UUID{}.NewID()
+
+	if first[6]>>4 != 7 || first[8]>>6 != 2 {
+		t.Errorf("Expected version 7 and the RFC variant, got %s", first)
+	}
+	if first.String() >= second.String() {
+		t.Errorf("Expected UUIDs to sort by time, got %s then %s", first, second)
+	}
+
+	parsed, err := UUID{}.ParseID(first.String())
+	if err != nil || parsed != first {
+		t.Errorf("Expected ParseID to round trip %s, got %s (%v)", first, parsed, err)
+	}
+	if _, err := (UUID{}).ParseID("not-a-uuid"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+
+	data, _ := bson.Marshal(bson.M{"_id": first})
+	if sub, _ := bson.Raw(data).Lookup("_id").Binary(); sub != 4 {
+		t.Errorf("Expected binary subtype 4, got %d", sub)
+	}
+	var decoded struct {
+		ID UUID `bson:"_id"`
+	}
+	if err := bson.Unmarshal(data, &decoded); err != nil || decoded.ID != first {
+		t.Errorf("Expected BSON to round trip, got %s (%v)", decoded.ID, err)
+	}
+
+	text, _ := json.Marshal(first)
+	if string(text) != `"`+first.String()+`"` {
+		t.Errorf("Expected JSON to use the canonical form, got %s", text)
+	}
+}
+
+func TestULID(t *testing.T) {
+	clock := useFakeClock(t)
+
+	first := ULID{}.NewID()
+	clock.Advance(time.Millisecond)
+	second := ULID{}.NewID()
+
+	if len(first.String()) != 26 || first.String() >= second.String() {
+		t.Errorf("Expected 26 character ULIDs sorting by time, got %s then %s", first, second)
+	}
+
+	var largest ULID
+	for i := range largest {
+		largest[i] = 0xff
+	}
+	if largest.String() != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Errorf("Unexpected encoding of the largest ULID: %s", largest)
+	}
+
+	parsed, err := ULID{}.ParseID(strings.ToLower(first.String()))
+	if err != nil || parsed != first {
+		t.Errorf("Expected ParseID to round trip %s, got %s (%v)", first, parsed, err)
+	}
+	if _, err := (ULID{}).ParseID("8ZZZZZZZZZZZZZZZZZZZZZZZZZ"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID on overflow, got %v", err)
+	}
+
+	data, _ := bson.Marshal(bson.M{"_id": first})
+	if s := bson.Raw(data).Lookup("_id").StringValue(); s != first.String() {
+		t.Errorf("Expected the ULID to be stored as a string, got %q", s)
+	}
+}
+
+func TestBaseSetInsertMeta(t *testing.T) {
+	order := &TestOrder{Total: 100}
+	order.SetInsertMeta()
+	if order.ID == (UUID{}) || order.CreatedAt.IsZero() {
+		t.Fatal("Expected SetInsertMeta to generate an ID and set CreatedAt")
+	}
+
+	id := order.ID
+	order.SetInsertMeta()
+	if order.ID != id {
+		t.Error("Expected SetInsertMeta to keep an existing ID")
+	}
+
+	country := &TestCountry{Name: "Thailand"}
+	country.SetInsertMeta()
+	if country.GetID() != "" {
+		t.Errorf("Expected natural keys not to be generated, got %q", country.GetID())
+	}
+}
+
+func TestRepositoryCustomID(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("filters by the typed id", func(mt *mtest.T) {
+		repo := NewRepository[TestOrder](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		order := &TestOrder{Total: 100}
+		if err := repo.Create(context.Background(), order); err != nil {
+			mt.Fatalf("Create returned error: %v", err)
+		}
+		sent := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if sub, data := sent.Lookup("_id").Binary(); sub != 4 || UUID(data) != order.ID {
+			mt.Errorf("Expected the UUID to be inserted as binary, got subtype %d", sub)
+		}
+
+		data, _ := bson.Marshal(order)
+		var doc bson.D
+		_ = bson.Unmarshal(data, &doc)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.orders", mtest.FirstBatch, doc))
+
+		found, err := repo.FindByID(context.Background(), order.GetID())
+		if err != nil {
+			mt.Fatalf("FindByID returned error: %v", err)
+		}
+		if found.ID != order.ID || found.Total != 100 {
+			mt.Errorf("Expected the order to be decoded, got %+v", found)
+		}
+		if _, err := repo.FindByID(context.Background(), "bad"); !errors.Is(err, ErrInvalidID) {
+			mt.Errorf("Expected ErrInvalidID, got %v", err)
+		}
+	})
+
+	mt.Run("requires natural keys", func(mt *mtest.T) {
+		repo := NewRepository[TestCountry](mt.Coll)
+
+		if err := repo.Create(context.Background(), &TestCountry{Name: "Thailand"}); !errors.Is(err, ErrMissingID) {
+			mt.Errorf("Expected ErrMissingID, got %v", err)
+		}
+	})
+}
+
+func TestMemoryRepositoryCustomID(t *testing.T) {
+	ctx := context.Background()
+
+	countries := NewMemoryRepository[TestCountry]()
+	th := &TestCountry{Base: Base[StringID]{ID: "TH"}, Name: "Thailand"}
+	if err := countries.Create(ctx, th); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if err := countries.Create(ctx, &TestCountry{Base: Base[StringID]{ID: "TH"}}); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expected a duplicate natural key, got %v", err)
+	}
+	found, err := countries.FindByID(ctx, "TH")
+	if err != nil || found.Name != "Thailand" {
+		t.Errorf("Expected to find the country by its key, got %v (%v)", found, err)
+	}
+
+	clock := useFakeClock(t)
+	events := NewMemoryRepository[TestEvent]()
+	for _, kind := range []string{"a", "b", "c"} {
+		if err := events.Create(ctx, &TestEvent{Kind: kind}); err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	page, err := events.FindPage(ctx, nil, PageRequest{Limit: 2, Key: KeysetID})
+	if err != nil {
+		t.Fatalf("FindPage returned error: %v", err)
+	}
+	next, err := events.FindPage(ctx, nil, PageRequest{Limit: 2, Key: KeysetID, After: page.Next})
+	if err != nil {
+		t.Fatalf("FindPage returned error: %v", err)
+	}
+	if len(next.Items) != 1 || next.Items[0].Kind != "c" {
+		t.Errorf("Expected the last event on the second page, got %v", next.Items)
+	}
+}
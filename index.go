@@ -0,0 +1,428 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexSpec declares an index on a model's collection
+// Keys use 1 / -1 for ascending / descending and "text" for text indexes
+//
+// ActiveOnly limits the index to documents that are not soft deleted, so a
+// unique value can be reused once the document holding it is deleted
+type IndexSpec struct {
+	Name        string
+	Keys        bson.D
+	Unique      bool
+	ActiveOnly  bool
+	ExpireAfter *time.Duration
+	Partial     bson.D
+}
+
+// Indexer is implemented by models that declare their indexes in code
+type Indexer interface {
+	Indexes() []IndexSpec
+}
+
+// CollectionNamer is implemented by models that know their collection name
+// EnsureIndexes requires it to find the collection of each model
+type CollectionNamer interface {
+	CollectionName() string
+}
+
+// IndexDrift describes a difference between a declared and an existing index
+type IndexDrift struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// IndexReport summarizes what EnsureIndexes did for one collection
+type IndexReport struct {
+	Collection string       `json:"collection"`
+	Created    []string     `json:"created,omitempty"`
+	Existing   []string     `json:"existing,omitempty"`
+	Drift      []IndexDrift `json:"drift,omitempty"`
+}
+
+// EnsureIndexes creates the missing indexes of every model and reports drift
+// between the declared indexes and those already in the database
+// Indexes are collected from the default base field indexes, `index` struct
+// tags and the Indexes method. Drifted indexes are reported, never dropped
+//
+// Struct tag syntax, comma separated:
+//
+//	index:"asc"                  single field ascending index (default direction)
+//	index:"desc,unique"          descending unique index
+//	index:"text"                 text index
+//	index:"unique,active"        unique among documents that are not soft deleted
+//	index:"ttl=720h"             TTL index expiring after the duration
+//	index:"name=email_tenant"    fields sharing a name form one compound index
+func EnsureIndexes(ctx context.Context, db *mongo.Database, models ...Model) ([]IndexReport, error) {
+	reports := make([]IndexReport, 0, len(models))
+	for _, m := range models {
+		namer, ok := m.(CollectionNamer)
+		if !ok {
+			return reports, fmt.Errorf("basemodel: %T does not implement CollectionNamer", m)
+		}
+
+		specs, err := ModelIndexes(m)
+		if err != nil {
+			return reports, err
+		}
+
+		report, err := ensureCollectionIndexes(ctx, db.Collection(namer.CollectionName()), specs)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// ModelIndexes returns every index declared for a model: the default base
+// field indexes, the `index` struct tags and the Indexes method
+func ModelIndexes(m Model) ([]IndexSpec, error) {
+	specs := defaultIndexes(m)
+
+	tagged, err := taggedIndexes(m)
+	if err != nil {
+		return nil, err
+	}
+	specs = append(specs, tagged...)
+
+	if indexer, ok := m.(Indexer); ok {
+		specs = append(specs, indexer.Indexes()...)
+	}
+
+	for i := range specs {
+		if specs[i].Name == "" {
+			specs[i].Name = indexName(specs[i].Keys)
+		}
+	}
+
+	return specs, nil
+}
+
+// defaultIndexes returns the indexes every base model benefits from:
+// deleted_at for the soft delete scope and (created_at, _id) for keyset pages,
+// plus tenant_id for tenant models whose every query filters on it and a
+// TTL index on expires_at for expiring models
+func defaultIndexes(m Model) []IndexSpec {
+	specs := []IndexSpec{
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+	}
+	if _, ok := m.(tenantScoped); ok {
+		specs = append(specs, IndexSpec{Keys: bson.D{{Key: "tenant_id", Value: 1}}})
+	}
+	if _, ok := m.(expiring); ok {
+		// Expire exactly at expires_at
+		var atExpiry time.Duration
+		specs = append(specs, IndexSpec{Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAfter: &atExpiry})
+	}
+
+	return specs
+}
+
+// taggedIndexes parses the `index` struct tags of a model
+func taggedIndexes(m Model) ([]IndexSpec, error) {
+	t := reflect.TypeOf(m)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var specs []IndexSpec
+	named := make(map[string]int)
+	if err := collectIndexTags(t, "", &specs, named); err != nil {
+		return nil, err
+	}
+
+	return specs, nil
+}
+
+func collectIndexTags(t reflect.Type, prefix string, specs *[]IndexSpec, named map[string]int) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := bsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if inline {
+			if ft.Kind() == reflect.Struct {
+				if err := collectIndexTags(ft, prefix, specs, named); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path := prefix + name
+		if tag, ok := field.Tag.Lookup("index"); ok {
+			if err := addIndexTag(path, tag, specs, named); err != nil {
+				return err
+			}
+		}
+
+		if ft.Kind() == reflect.Struct && ft != timeType {
+			if err := collectIndexTags(ft, path+".", specs, named); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// addIndexTag adds a field to a new index or to the compound index it names
+func addIndexTag(path, tag string, specs *[]IndexSpec, named map[string]int) error {
+	var direction interface{} = 1
+	spec := IndexSpec{}
+
+	for _, opt := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "", "asc":
+		case "desc":
+			direction = -1
+		case "text":
+			direction = "text"
+		case "unique":
+			spec.Unique = true
+		case "active":
+			spec.ActiveOnly = true
+		case "name":
+			spec.Name = value
+		case "ttl":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("basemodel: field %s: invalid ttl %q", path, value)
+			}
+			spec.ExpireAfter = &d
+		default:
+			return fmt.Errorf("basemodel: field %s: unknown index option %q", path, key)
+		}
+	}
+
+	key := bson.E{Key: path, Value: direction}
+	if spec.Name != "" {
+		if i, ok := named[spec.Name]; ok {
+			existing := &(*specs)[i]
+			existing.Keys = append(existing.Keys, key)
+			existing.Unique = existing.Unique || spec.Unique
+			existing.ActiveOnly = existing.ActiveOnly || spec.ActiveOnly
+			if spec.ExpireAfter != nil {
+				existing.ExpireAfter = spec.ExpireAfter
+			}
+			return nil
+		}
+		named[spec.Name] = len(*specs)
+	}
+
+	spec.Keys = bson.D{key}
+	*specs = append(*specs, spec)
+
+	return nil
+}
+
+// indexName builds the default MongoDB index name, for example "email_1"
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		parts = append(parts, k.Key, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// existingIndex is an index as reported by listIndexes
+type existingIndex struct {
+	Name               string   `bson:"name"`
+	Key                bson.Raw `bson:"key"`
+	Unique             bool     `bson:"unique"`
+	ExpireAfterSeconds *int64   `bson:"expireAfterSeconds"`
+	Partial            bson.Raw `bson:"partialFilterExpression"`
+}
+
+func ensureCollectionIndexes(ctx context.Context, coll *mongo.Collection, specs []IndexSpec) (IndexReport, error) {
+	report := IndexReport{Collection: coll.Name()}
+
+	var existing []existingIndex
+	cursor, err := coll.Indexes().List(ctx)
+	switch {
+	case isNamespaceNotFound(err):
+		// The collection does not exist yet; every index is missing
+	case err != nil:
+		return report, err
+	default:
+		if err := cursor.All(ctx, &existing); err != nil {
+			return report, err
+		}
+	}
+
+	byName := make(map[string]existingIndex, len(existing))
+	for _, idx := range existing {
+		byName[idx.Name] = idx
+	}
+
+	declared := make(map[string]bool, len(specs))
+	var missing []mongo.IndexModel
+	for _, spec := range specs {
+		declared[spec.Name] = true
+
+		idx, ok := byName[spec.Name]
+		if !ok {
+			missing = append(missing, spec.model())
+			report.Created = append(report.Created, spec.Name)
+			continue
+		}
+
+		report.Existing = append(report.Existing, spec.Name)
+		if reason := spec.drift(idx); reason != "" {
+			report.Drift = append(report.Drift, IndexDrift{Name: spec.Name, Reason: reason})
+		}
+	}
+
+	for _, idx := range existing {
+		if idx.Name != "_id_" && !declared[idx.Name] {
+			report.Drift = append(report.Drift, IndexDrift{Name: idx.Name, Reason: "index is not declared"})
+		}
+	}
+
+	if len(missing) > 0 {
+		if _, err := coll.Indexes().CreateMany(ctx, missing); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+// isNamespaceNotFound reports whether err is the server's NamespaceNotFound error
+func isNamespaceNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && cmdErr.Code == 26
+}
+
+// model converts the spec into a driver index model
+func (s IndexSpec) model() mongo.IndexModel {
+	opts := options.Index().SetName(s.Name)
+	if s.Unique {
+		opts.SetUnique(true)
+	}
+	if s.ExpireAfter != nil {
+		opts.SetExpireAfterSeconds(int32(s.ExpireAfter.Seconds()))
+	}
+	if partial := s.partialFilter(); partial != nil {
+		opts.SetPartialFilterExpression(partial)
+	}
+
+	return mongo.IndexModel{Keys: s.Keys, Options: opts}
+}
+
+// partialFilter returns the partial filter expression of the index,
+// including the active soft delete state for ActiveOnly indexes
+func (s IndexSpec) partialFilter() bson.D {
+	if !s.ActiveOnly {
+		return s.Partial
+	}
+
+	partial := make(bson.D, 0, len(s.Partial)+1)
+	partial = append(partial, s.Partial...)
+	return append(partial, scopeActive.predicate()...)
+}
+
+// drift returns why an existing index differs from the spec, or "" when it matches
+func (s IndexSpec) drift(idx existingIndex) string {
+	if !sameKeys(s.Keys, idx.Key) {
+		return fmt.Sprintf("keys differ: declared %v, existing %s", s.Keys, idx.Key)
+	}
+	if s.Unique != idx.Unique {
+		return fmt.Sprintf("unique differs: declared %t, existing %t", s.Unique, idx.Unique)
+	}
+
+	declaredTTL := int64(-1)
+	if s.ExpireAfter != nil {
+		declaredTTL = int64(s.ExpireAfter.Seconds())
+	}
+	existingTTL := int64(-1)
+	if idx.ExpireAfterSeconds != nil {
+		existingTTL = *idx.ExpireAfterSeconds
+	}
+	if declaredTTL != existingTTL {
+		return "expireAfterSeconds differs: declared " + ttlString(declaredTTL) + ", existing " + ttlString(existingTTL)
+	}
+
+	if partial := s.partialFilter(); !sameDocument(partial, idx.Partial) {
+		return fmt.Sprintf("partial filter differs: declared %v, existing %s", partial, idx.Partial)
+	}
+
+	return ""
+}
+
+func ttlString(seconds int64) string {
+	if seconds < 0 {
+		return "none"
+	}
+	return strconv.FormatInt(seconds, 10)
+}
+
+// sameKeys compares declared keys with an existing key document
+// Text indexes are stored as {_fts: "text", _ftsx: 1}, so they only
+// require the existing index to be a text index
+func sameKeys(declared bson.D, existing bson.Raw) bool {
+	for _, k := range declared {
+		if k.Value == "text" {
+			_, err := existing.LookupErr("_fts")
+			return err == nil
+		}
+	}
+
+	elems, err := existing.Elements()
+	if err != nil || len(elems) != len(declared) {
+		return false
+	}
+	for i, k := range declared {
+		if elems[i].Key() != k.Key {
+			return false
+		}
+		dir, ok := elems[i].Value().AsInt64OK()
+		if !ok || fmt.Sprint(dir) != fmt.Sprint(k.Value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameDocument compares a declared document with an existing raw document
+func sameDocument(declared bson.D, existing bson.Raw) bool {
+	if len(declared) == 0 {
+		return len(existing) == 0
+	}
+	data, err := bson.Marshal(declared)
+	if err != nil {
+		return false
+	}
+	return bson.Raw(data).String() == existing.String()
+}
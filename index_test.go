@@ -0,0 +1,194 @@
+package basemodel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestArticle is a test struct declaring indexes with tags and an Indexes method
+type TestArticle struct {
+	BaseCollection `bson:",inline"`
+	Slug           string    `json:"slug" bson:"slug" index:"unique"`
+	Title          string    `json:"title" bson:"title" index:"text"`
+	TenantID       string    `json:"tenant_id" bson:"tenant_id" index:"name=tenant_published"`
+	PublishedAt    time.Time `json:"published_at" bson:"published_at" index:"desc,name=tenant_published"`
+	Status         string    `json:"status" bson:"status"`
+}
+
+func (a *TestArticle) CollectionName() string {
+	return "articles"
+}
+
+func (a *TestArticle) Indexes() []IndexSpec {
+	return []IndexSpec{
+		{Keys: bson.D{{Key: "status", Value: 1}}, Partial: bson.D{{Key: "status", Value: "draft"}}},
+	}
+}
+
+// indexNamed finds a spec by name
+func indexNamed(specs []IndexSpec, name string) (IndexSpec, bool) {
+	for _, s := range specs {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return IndexSpec{}, false
+}
+
+func TestModelIndexes(t *testing.T) {
+	specs, err := ModelIndexes(&TestArticle{})
+	if err != nil {
+		t.Fatalf("ModelIndexes returned error: %v", err)
+	}
+
+	for _, name := range []string{"deleted_at_1", "created_at_1__id_1"} {
+		if _, ok := indexNamed(specs, name); !ok {
+			t.Errorf("Expected default index %s", name)
+		}
+	}
+
+	slug, ok := indexNamed(specs, "slug_1")
+	if !ok || !slug.Unique {
+		t.Errorf("Expected unique slug_1 index, got %+v", slug)
+	}
+
+	title, ok := indexNamed(specs, "title_text")
+	if !ok || title.Keys[0].Value != "text" {
+		t.Errorf("Expected title_text index, got %+v", title)
+	}
+
+	compound, ok := indexNamed(specs, "tenant_published")
+	if !ok || len(compound.Keys) != 2 {
+		t.Fatalf("Expected compound tenant_published index, got %+v", compound)
+	}
+	if compound.Keys[0].Key != "tenant_id" || compound.Keys[1].Key != "published_at" || compound.Keys[1].Value != -1 {
+		t.Errorf("Expected keys (tenant_id 1, published_at -1), got %v", compound.Keys)
+	}
+
+	status, ok := indexNamed(specs, "status_1")
+	if !ok || status.Partial == nil {
+		t.Errorf("Expected partial status_1 index from Indexes, got %+v", status)
+	}
+}
+
+func TestModelIndexesTTL(t *testing.T) {
+	type session struct {
+		BaseCollection `bson:",inline"`
+		SeenAt         time.Time `bson:"seen_at" index:"ttl=24h"`
+	}
+
+	specs, err := ModelIndexes(&session{})
+	if err != nil {
+		t.Fatalf("ModelIndexes returned error: %v", err)
+	}
+
+	seen, ok := indexNamed(specs, "seen_at_1")
+	if !ok || seen.ExpireAfter == nil || *seen.ExpireAfter != 24*time.Hour {
+		t.Errorf("Expected seen_at_1 TTL of 24h, got %+v", seen)
+	}
+}
+
+func TestModelIndexesActiveOnly(t *testing.T) {
+	type customer struct {
+		BaseCollection `bson:",inline"`
+		Email          string `bson:"email" index:"unique,active"`
+	}
+
+	specs, err := ModelIndexes(&customer{})
+	if err != nil {
+		t.Fatalf("ModelIndexes returned error: %v", err)
+	}
+
+	email, ok := indexNamed(specs, "email_1")
+	if !ok || !email.Unique || !email.ActiveOnly {
+		t.Fatalf("Expected unique active-only email_1 index, got %+v", email)
+	}
+
+	opts := email.model().Options
+	partial, ok := opts.PartialFilterExpression.(bson.D)
+	if !ok || len(partial) != 1 || partial[0].Key != "deleted_at" || partial[0].Value != nil {
+		t.Errorf("Expected partial filter {deleted_at: null}, got %v", opts.PartialFilterExpression)
+	}
+}
+
+func TestModelIndexesInvalidTag(t *testing.T) {
+	type broken struct {
+		BaseCollection `bson:",inline"`
+		Name           string `bson:"name" index:"sideways"`
+	}
+
+	if _, err := ModelIndexes(&broken{}); err == nil {
+		t.Error("Expected an error for an unknown index option")
+	}
+}
+
+func TestEnsureIndexes(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("creates missing indexes and reports drift", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.articles", mtest.FirstBatch,
+				bson.D{{Key: "name", Value: "_id_"}, {Key: "key", Value: bson.D{{Key: "_id", Value: 1}}}},
+				bson.D{{Key: "name", Value: "deleted_at_1"}, {Key: "key", Value: bson.D{{Key: "deleted_at", Value: 1}}}},
+				bson.D{{Key: "name", Value: "slug_1"}, {Key: "key", Value: bson.D{{Key: "slug", Value: 1}}}},
+				bson.D{{Key: "name", Value: "legacy_1"}, {Key: "key", Value: bson.D{{Key: "legacy", Value: 1}}}},
+			),
+			mtest.CreateSuccessResponse(),
+		)
+
+		reports, err := EnsureIndexes(context.Background(), mt.DB, &TestArticle{})
+		if err != nil {
+			mt.Fatalf("EnsureIndexes returned error: %v", err)
+		}
+		if len(reports) != 1 {
+			mt.Fatalf("Expected 1 report, got %d", len(reports))
+		}
+
+		report := reports[0]
+		if report.Collection != "articles" {
+			mt.Errorf("Expected collection articles, got %s", report.Collection)
+		}
+		if len(report.Existing) != 2 {
+			mt.Errorf("Expected 2 existing indexes, got %v", report.Existing)
+		}
+		if len(report.Created) != 4 {
+			mt.Errorf("Expected 4 created indexes, got %v", report.Created)
+		}
+
+		drift := make(map[string]string)
+		for _, d := range report.Drift {
+			drift[d.Name] = d.Reason
+		}
+		if _, ok := drift["slug_1"]; !ok {
+			mt.Error("Expected drift for slug_1 missing its unique option")
+		}
+		if drift["legacy_1"] != "index is not declared" {
+			mt.Errorf("Expected legacy_1 to be reported as undeclared, got %q", drift["legacy_1"])
+		}
+		if _, ok := drift["deleted_at_1"]; ok {
+			mt.Error("Expected no drift for a matching index")
+		}
+
+		event := mt.GetStartedEvent()
+		for event != nil && event.CommandName != "createIndexes" {
+			event = mt.GetStartedEvent()
+		}
+		if event == nil {
+			mt.Fatal("Expected a createIndexes command")
+		}
+		values, err := event.Command.Lookup("indexes").Array().Values()
+		if err != nil || len(values) != 4 {
+			mt.Errorf("Expected 4 indexes in createIndexes, got %d", len(values))
+		}
+	})
+
+	mt.Run("requires a collection name", func(mt *mtest.T) {
+		if _, err := EnsureIndexes(context.Background(), mt.DB, &TestUser{}); err == nil {
+			mt.Error("Expected an error for a model without CollectionName")
+		}
+	})
+}
@@ -0,0 +1,622 @@
+package basemodel
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// normalizeDocument converts a filter, update or sort given as bson.D, bson.M
+// or a struct into a bson.D holding the values the driver decodes, so that
+// time.Time becomes primitive.DateTime and bson.M becomes bson.D
+func normalizeDocument(v interface{}) (bson.D, error) {
+	if v == nil {
+		return bson.D{}, nil
+	}
+
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeDocument(data)
+}
+
+// decodeDocument decodes raw BSON into a fresh bson.D
+func decodeDocument(raw bson.Raw) (bson.D, error) {
+	var doc bson.D
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// unsupported reports a query or update operator the matcher does not implement
+func unsupported(operator string) error {
+	return fmt.Errorf("basemodel: operator %s is not supported in memory", operator)
+}
+
+// unsupportedOption reports an option the memory repository does not implement
+func unsupportedOption(option string) error {
+	return fmt.Errorf("basemodel: option %s is not supported in memory", option)
+}
+
+// matchDocument reports whether the normalized document matches the
+// normalized filter, following MongoDB query semantics
+func matchDocument(doc, filter bson.D) (bool, error) {
+	for _, e := range filter {
+		ok, err := matchElement(doc, e)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchElement evaluates a single top level filter element
+func matchElement(doc bson.D, e bson.E) (bool, error) {
+	switch e.Key {
+	case "$and", "$or", "$nor":
+		clauses, ok := e.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("basemodel: %s needs an array", e.Key)
+		}
+		matched := 0
+		for _, clause := range clauses {
+			sub, ok := clause.(bson.D)
+			if !ok {
+				return false, fmt.Errorf("basemodel: %s needs an array of documents", e.Key)
+			}
+			ok, err := matchDocument(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				matched++
+			}
+		}
+		switch e.Key {
+		case "$and":
+			return matched == len(clauses), nil
+		case "$or":
+			return matched > 0, nil
+		default:
+			return matched == 0, nil
+		}
+	}
+	if strings.HasPrefix(e.Key, "$") {
+		return false, unsupported(e.Key)
+	}
+
+	values, found := lookupPath(doc, e.Key)
+	if ops, ok := operatorDocument(e.Value); ok {
+		return matchOperators(values, found, ops)
+	}
+	if re, ok := e.Value.(primitive.Regex); ok {
+		return matchRegex(values, re)
+	}
+
+	return matchEqual(values, found, e.Value), nil
+}
+
+// operatorDocument returns v as an operator document such as {$gt: 1}
+func operatorDocument(v interface{}) (bson.D, bool) {
+	doc, ok := v.(bson.D)
+	if !ok || len(doc) == 0 || !strings.HasPrefix(doc[0].Key, "$") {
+		return nil, false
+	}
+	return doc, true
+}
+
+// lookupPath returns the values a dotted path resolves to
+// Arrays along the path are traversed element by element, and an array at
+// the end of the path yields both the array and each of its elements
+func lookupPath(doc bson.D, path string) ([]interface{}, bool) {
+	return lookupParts(doc, strings.Split(path, "."))
+}
+
+func lookupParts(v interface{}, parts []string) ([]interface{}, bool) {
+	if len(parts) == 0 {
+		if arr, ok := v.(bson.A); ok {
+			return append([]interface{}{arr}, arr...), true
+		}
+		return []interface{}{v}, true
+	}
+
+	switch t := v.(type) {
+	case bson.D:
+		for _, e := range t {
+			if e.Key == parts[0] {
+				return lookupParts(e.Value, parts[1:])
+			}
+		}
+	case bson.A:
+		if i, err := strconv.Atoi(parts[0]); err == nil {
+			if i >= 0 && i < len(t) {
+				return lookupParts(t[i], parts[1:])
+			}
+			return nil, false
+		}
+		var values []interface{}
+		found := false
+		for _, elem := range t {
+			if vs, ok := lookupParts(elem, parts); ok {
+				values = append(values, vs...)
+				found = true
+			}
+		}
+		return values, found
+	}
+
+	return nil, false
+}
+
+// matchEqual reports whether any value equals want; a null want also
+// matches a missing field
+func matchEqual(values []interface{}, found bool, want interface{}) bool {
+	if !found {
+		return typeClass(want) == classNull
+	}
+	for _, v := range values {
+		if compareValues(v, want) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOperators evaluates an operator document against the field values
+func matchOperators(values []interface{}, found bool, ops bson.D) (bool, error) {
+	var regexOptions string
+	for _, op := range ops {
+		if op.Key == "$options" {
+			regexOptions, _ = op.Value.(string)
+		}
+	}
+
+	for _, op := range ops {
+		ok, err := matchOperator(values, found, op, regexOptions)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchOperator(values []interface{}, found bool, op bson.E, regexOptions string) (bool, error) {
+	switch op.Key {
+	case "$eq":
+		return matchEqual(values, found, op.Value), nil
+	case "$ne":
+		return !matchEqual(values, found, op.Value), nil
+	case "$gt", "$gte", "$lt", "$lte":
+		for _, v := range values {
+			if typeClass(v) != typeClass(op.Value) {
+				continue
+			}
+			c := compareValues(v, op.Value)
+			if (op.Key == "$gt" && c > 0) || (op.Key == "$gte" && c >= 0) ||
+				(op.Key == "$lt" && c < 0) || (op.Key == "$lte" && c <= 0) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$in", "$nin":
+		list, ok := op.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("basemodel: %s needs an array", op.Key)
+		}
+		in := false
+		for _, want := range list {
+			if matchEqual(values, found, want) {
+				in = true
+				break
+			}
+		}
+		return in == (op.Key == "$in"), nil
+	case "$all":
+		list, ok := op.Value.(bson.A)
+		if !ok {
+			return false, fmt.Errorf("basemodel: $all needs an array")
+		}
+		for _, want := range list {
+			if !matchEqual(values, found, want) {
+				return false, nil
+			}
+		}
+		return len(list) > 0, nil
+	case "$exists":
+		return found == truthy(op.Value), nil
+	case "$size":
+		n, ok := toInt64(op.Value)
+		if !ok {
+			return false, fmt.Errorf("basemodel: $size needs an integer")
+		}
+		for _, v := range values {
+			if arr, ok := v.(bson.A); ok && int64(len(arr)) == n {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "$regex":
+		re, ok := op.Value.(primitive.Regex)
+		if !ok {
+			pattern, isString := op.Value.(string)
+			if !isString {
+				return false, fmt.Errorf("basemodel: $regex needs a string or regex")
+			}
+			re = primitive.Regex{Pattern: pattern, Options: regexOptions}
+		}
+		return matchRegex(values, re)
+	case "$options":
+		return true, nil
+	case "$not":
+		if re, ok := op.Value.(primitive.Regex); ok {
+			matched, err := matchRegex(values, re)
+			return !matched, err
+		}
+		sub, ok := operatorDocument(op.Value)
+		if !ok {
+			return false, fmt.Errorf("basemodel: $not needs an operator document or regex")
+		}
+		matched, err := matchOperators(values, found, sub)
+		return !matched, err
+	case "$elemMatch":
+		sub, ok := op.Value.(bson.D)
+		if !ok {
+			return false, fmt.Errorf("basemodel: $elemMatch needs a document")
+		}
+		ops, isOps := operatorDocument(sub)
+		for _, v := range values {
+			arr, ok := v.(bson.A)
+			if !ok {
+				continue
+			}
+			for _, elem := range arr {
+				var matched bool
+				var err error
+				if isOps {
+					matched, err = matchOperators([]interface{}{elem}, true, ops)
+				} else if doc, isDoc := elem.(bson.D); isDoc {
+					matched, err = matchDocument(doc, sub)
+				}
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+
+	return false, unsupported(op.Key)
+}
+
+// matchRegex reports whether any string value matches the regex
+func matchRegex(values []interface{}, re primitive.Regex) (bool, error) {
+	pattern := re.Pattern
+	var flags strings.Builder
+	for _, o := range re.Options {
+		if strings.ContainsRune("ims", o) {
+			flags.WriteRune(o)
+		}
+	}
+	if flags.Len() > 0 {
+		pattern = "(?" + flags.String() + ")" + pattern
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range values {
+		if s, ok := v.(string); ok && compiled.MatchString(s) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// BSON comparison order classes, lowest first
+const (
+	classNull = iota + 1
+	classNumber
+	classString
+	classDocument
+	classArray
+	classBinary
+	classObjectID
+	classBool
+	classDate
+	classTimestamp
+	classRegex
+	classOther
+)
+
+// typeClass returns the BSON comparison order class of a value
+// Only values of the same class are compared by range operators
+func typeClass(v interface{}) int {
+	switch v.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return classNull
+	case int, int32, int64, float64:
+		return classNumber
+	case string:
+		return classString
+	case bson.D:
+		return classDocument
+	case bson.A:
+		return classArray
+	case primitive.Binary:
+		return classBinary
+	case primitive.ObjectID:
+		return classObjectID
+	case bool:
+		return classBool
+	case primitive.DateTime:
+		return classDate
+	case primitive.Timestamp:
+		return classTimestamp
+	case primitive.Regex:
+		return classRegex
+	default:
+		return classOther
+	}
+}
+
+// compareValues orders two normalized values the way MongoDB sorts them
+func compareValues(a, b interface{}) int {
+	ca, cb := typeClass(a), typeClass(b)
+	if ca != cb {
+		return cmp.Compare(ca, cb)
+	}
+
+	switch x := a.(type) {
+	case int, int32, int64, float64:
+		ai, aInt := toInt64(a)
+		bi, bInt := toInt64(b)
+		if aInt && bInt {
+			return cmp.Compare(ai, bi)
+		}
+		return cmp.Compare(toFloat64(a), toFloat64(b))
+	case string:
+		return strings.Compare(x, b.(string))
+	case bson.D:
+		y := b.(bson.D)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := strings.Compare(x[i].Key, y[i].Key); c != 0 {
+				return c
+			}
+			if c := compareValues(x[i].Value, y[i].Value); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(len(x), len(y))
+	case bson.A:
+		y := b.(bson.A)
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareValues(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return cmp.Compare(len(x), len(y))
+	case primitive.Binary:
+		y := b.(primitive.Binary)
+		if c := cmp.Compare(len(x.Data), len(y.Data)); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(x.Subtype, y.Subtype); c != 0 {
+			return c
+		}
+		return bytes.Compare(x.Data, y.Data)
+	case primitive.ObjectID:
+		y := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:])
+	case bool:
+		y := b.(bool)
+		switch {
+		case x == y:
+			return 0
+		case !x:
+			return -1
+		default:
+			return 1
+		}
+	case primitive.DateTime:
+		return cmp.Compare(x, b.(primitive.DateTime))
+	case primitive.Timestamp:
+		return primitive.CompareTimestamp(x, b.(primitive.Timestamp))
+	case primitive.Regex:
+		y := b.(primitive.Regex)
+		if c := strings.Compare(x.Pattern, y.Pattern); c != 0 {
+			return c
+		}
+		return strings.Compare(x.Options, y.Options)
+	}
+
+	return 0
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) float64 {
+	if n, ok := toInt64(v); ok {
+		return float64(n)
+	}
+	f, _ := v.(float64)
+	return f
+}
+
+// truthy converts a $exists style argument to a bool
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case nil:
+		return false
+	case int, int32, int64, float64:
+		return toFloat64(x) != 0
+	}
+	return true
+}
+
+// compareDocuments orders two documents by a normalized sort specification
+func compareDocuments(a, b, spec bson.D) int {
+	for _, key := range spec {
+		dir := 1
+		if toFloat64(key.Value) < 0 {
+			dir = -1
+		}
+		if c := compareValues(sortValue(a, key.Key), sortValue(b, key.Key)); c != 0 {
+			return c * dir
+		}
+	}
+	return 0
+}
+
+// sortValue returns the value a document sorts by, nil when the field is missing
+func sortValue(doc bson.D, path string) interface{} {
+	values, found := lookupPath(doc, path)
+	if !found || len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// applyUpdate applies the $set, $unset, $inc and, when inserting,
+// $setOnInsert operators of a normalized update to doc
+func applyUpdate(doc, update bson.D, inserting bool) (bson.D, error) {
+	for _, op := range update {
+		fields, ok := op.Value.(bson.D)
+		if !ok {
+			return nil, fmt.Errorf("basemodel: %s needs a document", op.Key)
+		}
+
+		for _, f := range fields {
+			switch op.Key {
+			case "$set":
+				doc = setPath(doc, f.Key, f.Value)
+			case "$setOnInsert":
+				if inserting {
+					doc = setPath(doc, f.Key, f.Value)
+				}
+			case "$unset":
+				doc = unsetPath(doc, f.Key)
+			case "$inc":
+				if typeClass(f.Value) != classNumber {
+					return nil, fmt.Errorf("basemodel: $inc needs a number for %s", f.Key)
+				}
+				current := sortValue(doc, f.Key)
+				if current == nil {
+					doc = setPath(doc, f.Key, f.Value)
+					continue
+				}
+				if typeClass(current) != classNumber {
+					return nil, fmt.Errorf("basemodel: $inc on non-numeric field %s", f.Key)
+				}
+				doc = setPath(doc, f.Key, addNumbers(current, f.Value))
+			default:
+				return nil, unsupported(op.Key)
+			}
+		}
+	}
+
+	return doc, nil
+}
+
+// upsertSeed returns the equality conditions of a query, which MongoDB
+// copies into the document an upsert inserts
+func upsertSeed(query bson.D) bson.D {
+	seed := bson.D{}
+	for _, e := range query {
+		switch {
+		case e.Key == "$and":
+			clauses, _ := e.Value.(bson.A)
+			for _, clause := range clauses {
+				if sub, ok := clause.(bson.D); ok {
+					for _, s := range upsertSeed(sub) {
+						seed = setPath(seed, s.Key, s.Value)
+					}
+				}
+			}
+		case strings.HasPrefix(e.Key, "$"):
+		default:
+			if ops, ok := operatorDocument(e.Value); ok {
+				if len(ops) == 1 && ops[0].Key == "$eq" {
+					seed = setPath(seed, e.Key, ops[0].Value)
+				}
+				continue
+			}
+			seed = setPath(seed, e.Key, e.Value)
+		}
+	}
+	return seed
+}
+
+// addNumbers adds two numbers, keeping integers as int64
+func addNumbers(a, b interface{}) interface{} {
+	ai, aInt := toInt64(a)
+	bi, bInt := toInt64(b)
+	if aInt && bInt {
+		return ai + bi
+	}
+	return toFloat64(a) + toFloat64(b)
+}
+
+// setPath sets the value at a dotted path, creating missing documents
+func setPath(doc bson.D, path string, value interface{}) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			doc[i].Value = value
+			return doc
+		}
+		child, _ := e.Value.(bson.D)
+		doc[i].Value = setPath(child, rest, value)
+		return doc
+	}
+
+	if !nested {
+		return append(doc, bson.E{Key: key, Value: value})
+	}
+	return append(doc, bson.E{Key: key, Value: setPath(nil, rest, value)})
+}
+
+// unsetPath removes the value at a dotted path
+func unsetPath(doc bson.D, path string) bson.D {
+	key, rest, nested := strings.Cut(path, ".")
+	for i, e := range doc {
+		if e.Key != key {
+			continue
+		}
+		if !nested {
+			return append(doc[:i], doc[i+1:]...)
+		}
+		if child, ok := e.Value.(bson.D); ok {
+			doc[i].Value = unsetPath(child, rest)
+		}
+		return doc
+	}
+	return doc
+}
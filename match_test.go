@@ -0,0 +1,148 @@
+package basemodel
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMatchDocument(t *testing.T) {
+	now := time.Now()
+	doc, err := normalizeDocument(bson.M{
+		"name":       "Jane",
+		"age":        int32(30),
+		"score":      7.5,
+		"tags":       bson.A{"admin", "ops"},
+		"address":    bson.M{"city": "Bangkok"},
+		"created_at": now,
+		"deleted_at": nil,
+	})
+	if err != nil {
+		t.Fatalf("normalizeDocument returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   bson.D
+		expected bool
+	}{
+		{"equality", Field("name").Eq("Jane"), true},
+		{"inequality", Field("name").Ne("Jane"), false},
+		{"numbers of different types", bson.D{{Key: "age", Value: int64(30)}}, true},
+		{"greater than", bson.D{{Key: "age", Value: bson.D{{Key: "$gt", Value: 29.5}}}}, true},
+		{"range excludes other types", bson.D{{Key: "name", Value: bson.D{{Key: "$gt", Value: 1}}}}, false},
+		{"time range", Field("created_at").Range(now.Add(-time.Minute), now.Add(time.Minute)), true},
+		{"in", Field("name").In("John", "Jane"), true},
+		{"nin", Field("name").Nin("John", "Jane"), false},
+		{"array element", bson.D{{Key: "tags", Value: "ops"}}, true},
+		{"all", bson.D{{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{"ops", "admin"}}}}}, true},
+		{"size", bson.D{{Key: "tags", Value: bson.D{{Key: "$size", Value: 2}}}}, true},
+		{"nested path", bson.D{{Key: "address.city", Value: "Bangkok"}}, true},
+		{"null matches missing", bson.D{{Key: "phone", Value: nil}}, true},
+		{"null matches null", bson.D{{Key: "deleted_at", Value: nil}}, true},
+		{"ne null", bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}}, false},
+		{"exists", Field("phone").Exists(false), true},
+		{"regex", Field("name").Regex("^ja", "i"), true},
+		{"regex string", bson.D{{Key: "name", Value: bson.D{{Key: "$regex", Value: "^ja"}, {Key: "$options", Value: "i"}}}}, true},
+		{"not", bson.D{{Key: "age", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: 40}}}}}}, true},
+		{"and", And(Field("name").Eq("Jane"), Field("age").Lt(18)), false},
+		{"or", Or(Field("name").Eq("John"), Field("age").Gte(30)), true},
+		{"nor", bson.D{{Key: "$nor", Value: bson.A{bson.D{{Key: "name", Value: "Jane"}}}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := normalizeDocument(tt.filter)
+			if err != nil {
+				t.Fatalf("normalizeDocument returned error: %v", err)
+			}
+			got, err := matchDocument(doc, filter)
+			if err != nil {
+				t.Fatalf("matchDocument returned error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("unsupported operator", func(t *testing.T) {
+		filter := bson.D{{Key: "$where", Value: "true"}}
+		if _, err := matchDocument(doc, filter); err == nil {
+			t.Error("Expected an error for an unsupported operator")
+		}
+	})
+}
+
+func TestCompareValues(t *testing.T) {
+	early, late := primitive.NewObjectID(), primitive.NewObjectID()
+
+	tests := []struct {
+		name string
+		a, b interface{}
+		sign int
+	}{
+		{"null before numbers", nil, int32(1), -1},
+		{"int and float", int32(2), 1.5, 1},
+		{"strings", "a", "b", -1},
+		{"object ids", early, late, -1},
+		{"dates", primitive.DateTime(1), primitive.DateTime(2), -1},
+		{"equal documents", bson.D{{Key: "a", Value: int32(1)}}, bson.D{{Key: "a", Value: int64(1)}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareValues(tt.a, tt.b)
+			if (got < 0 && tt.sign >= 0) || (got > 0 && tt.sign <= 0) || (got == 0 && tt.sign != 0) {
+				t.Errorf("Expected sign %d, got %d", tt.sign, got)
+			}
+		})
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	doc := bson.D{{Key: "name", Value: "Jane"}, {Key: "version", Value: int64(1)}, {Key: "nick", Value: "J"}}
+	update, _ := normalizeDocument(bson.D{
+		{Key: "$set", Value: bson.D{{Key: "name", Value: "Janet"}, {Key: "address.city", Value: "Bangkok"}}},
+		{Key: "$unset", Value: bson.D{{Key: "nick", Value: ""}}},
+		{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+		{Key: "$setOnInsert", Value: bson.D{{Key: "created_at", Value: time.Now()}}},
+	})
+
+	got, err := applyUpdate(doc, update, false)
+	if err != nil {
+		t.Fatalf("applyUpdate returned error: %v", err)
+	}
+
+	expected := bson.D{
+		{Key: "name", Value: "Janet"},
+		{Key: "version", Value: int64(2)},
+		{Key: "address", Value: bson.D{{Key: "city", Value: "Bangkok"}}},
+	}
+	if compareValues(got, expected) != 0 {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if _, err := applyUpdate(bson.D{}, bson.D{{Key: "$push", Value: bson.D{{Key: "tags", Value: "x"}}}}, false); err == nil {
+		t.Error("Expected an error for an unsupported update operator")
+	}
+}
+
+func TestUpsertSeed(t *testing.T) {
+	query, _ := normalizeDocument(bson.M{"$and": bson.A{
+		bson.M{"email": "jane@example.com", "age": bson.M{"$gt": 18}},
+		bson.M{"tenant_id": bson.M{"$eq": "acme"}, "deleted_at": nil},
+	}})
+
+	got := upsertSeed(query)
+	for _, key := range []string{"email", "tenant_id", "deleted_at"} {
+		if _, ok := lookupPath(got, key); !ok {
+			t.Errorf("Expected %s in the seed, got %v", key, got)
+		}
+	}
+	if _, ok := lookupPath(got, "age"); ok {
+		t.Errorf("Expected range conditions not to be seeded, got %v", got)
+	}
+}
@@ -0,0 +1,837 @@
+package basemodel
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MemoryRepository is an in-memory implementation of Store for unit tests
+// Documents are kept as BSON in a map and filters are evaluated by a matcher
+// supporting the comparison, element, array and logical query operators, so
+// the same tests can run against it and against a real MongoDB
+//
+// Metadata, soft delete scopes, versioning, hooks, validation, tenants,
+// expiry, sorting, pagination and the unique indexes declared on the model
+// behave like Repository. Updates support $set, $unset and $inc; history,
+// Aggregate and bulk writes are not available
+type MemoryRepository[T any, PT document[T]] struct {
+	data  *memoryData
+	scope softDeleteScope
+}
+
+// memoryData is the state shared by a memory repository and its scoped copies
+type memoryData struct {
+	mu       sync.RWMutex
+	docs     map[string]memoryDoc
+	order    []string
+	unique   []IndexSpec
+	indexErr error
+}
+
+// memoryDoc is a stored document keyed by GetID; doc is decoded from raw
+// and never modified
+type memoryDoc struct {
+	id  string
+	raw bson.Raw
+	doc bson.D
+}
+
+// NewMemoryRepository creates an empty in-memory repository
+//
+//	users := basemodel.NewMemoryRepository[User]()
+func NewMemoryRepository[T any, PT document[T]]() *MemoryRepository[T, PT] {
+	data := &memoryData{docs: make(map[string]memoryDoc)}
+
+	specs, err := ModelIndexes(PT(new(T)))
+	for _, spec := range specs {
+		if spec.Unique {
+			data.unique = append(data.unique, spec)
+		}
+	}
+	data.indexErr = err
+
+	return &MemoryRepository[T, PT]{data: data}
+}
+
+// WithDeleted returns a copy of the repository whose queries include soft deleted documents
+func (r *MemoryRepository[T, PT]) WithDeleted() *MemoryRepository[T, PT] {
+	return r.withScope(scopeWithDeleted)
+}
+
+// OnlyDeleted returns a copy of the repository whose queries match only soft deleted documents
+func (r *MemoryRepository[T, PT]) OnlyDeleted() *MemoryRepository[T, PT] {
+	return r.withScope(scopeOnlyDeleted)
+}
+
+// withScope returns a copy of the repository sharing its documents
+func (r *MemoryRepository[T, PT]) withScope(scope softDeleteScope) *MemoryRepository[T, PT] {
+	scoped := *r
+	scoped.scope = scope
+	return &scoped
+}
+
+// Create sets the insert metadata, validates and stores the model
+// It returns a DuplicateKeyError when the model breaks a unique index
+func (r *MemoryRepository[T, PT]) Create(ctx context.Context, model PT) error {
+	if r.data.indexErr != nil {
+		return r.data.indexErr
+	}
+	if err := stampTenant(ctx, model); err != nil {
+		return err
+	}
+	if err := beforeInsert(ctx, model); err != nil {
+		return err
+	}
+
+	setInsertMeta(ctx, model)
+	if err := requireID(model); err != nil {
+		return err
+	}
+	if err := Validate(model); err != nil {
+		return err
+	}
+
+	raw, err := bson.Marshal(model)
+	if err != nil {
+		return err
+	}
+	if err := r.data.insert(model.GetID(), raw); err != nil {
+		return err
+	}
+
+	return afterInsert(ctx, model)
+}
+
+// FindByID finds a document by the string form of its ID
+func (r *MemoryRepository[T, PT]) FindByID(ctx context.Context, id string) (PT, error) {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindOne(ctx, bson.M{"_id": objID})
+}
+
+// FindOne returns the first document matching the filter
+// Sort and Skip are honored; other options are ignored
+func (r *MemoryRepository[T, PT]) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (PT, error) {
+	var sort interface{}
+	var skip int64
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			sort = o.Sort
+		}
+		if o.Skip != nil {
+			skip = *o.Skip
+		}
+	}
+
+	models, err := r.find(ctx, filter, sort, skip, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return models[0], nil
+}
+
+// Find returns all documents matching the filter
+// Sort, Skip and Limit are honored; other options are ignored
+func (r *MemoryRepository[T, PT]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]PT, error) {
+	var sort interface{}
+	var skip, limit int64
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Sort != nil {
+			sort = o.Sort
+		}
+		if o.Skip != nil {
+			skip = *o.Skip
+		}
+		if o.Limit != nil {
+			limit = *o.Limit
+		}
+	}
+
+	return r.find(ctx, filter, sort, skip, limit)
+}
+
+// FindAll returns all documents in the repository scope
+func (r *MemoryRepository[T, PT]) FindAll(ctx context.Context) ([]PT, error) {
+	return r.Find(ctx, bson.M{})
+}
+
+// FindDeleted returns all soft deleted documents
+func (r *MemoryRepository[T, PT]) FindDeleted(ctx context.Context) ([]PT, error) {
+	return r.OnlyDeleted().Find(ctx, bson.M{})
+}
+
+// FindPage returns a keyset page of documents matching the filter
+func (r *MemoryRepository[T, PT]) FindPage(ctx context.Context, filter interface{}, req PageRequest) (*Page[PT], error) {
+	return findPage[T, PT](ctx, r, filter, req)
+}
+
+// FindOffset returns an offset page of documents matching the filter
+func (r *MemoryRepository[T, PT]) FindOffset(ctx context.Context, filter interface{}, req OffsetRequest) (*Page[PT], error) {
+	return findOffset[T, PT](ctx, r, filter, req)
+}
+
+// Count returns the number of documents matching the filter
+// Skip and Limit are honored
+func (r *MemoryRepository[T, PT]) Count(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	query, err := r.query(ctx, r.scope, filter)
+	if err != nil {
+		return 0, err
+	}
+	docs, err := r.data.matching(query)
+	if err != nil {
+		return 0, err
+	}
+
+	count := int64(len(docs))
+	for _, o := range opts {
+		if o == nil {
+			continue
+		}
+		if o.Skip != nil {
+			count = max(count-*o.Skip, 0)
+		}
+		if o.Limit != nil && *o.Limit > 0 {
+			count = min(count, *o.Limit)
+		}
+	}
+
+	return count, nil
+}
+
+// Update sets the update metadata, validates and saves the model
+// It behaves like Repository.Update, including the version check
+func (r *MemoryRepository[T, PT]) Update(ctx context.Context, model PT) error {
+	if err := beforeUpdate(ctx, model); err != nil {
+		return err
+	}
+
+	setUpdateMeta(ctx, model)
+	if err := Validate(model); err != nil {
+		return err
+	}
+
+	update, err := updateDocument(model, nil)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.D{{Key: "_id", Value: model.documentID()}}
+	v, isVersioned := any(model).(versioned)
+	if isVersioned {
+		filter = append(filter, bson.E{Key: "version", Value: v.GetVersion()})
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}})
+	}
+
+	matched, _, err := r.update(ctx, r.scope, filter, update, false)
+	if err != nil {
+		return err
+	}
+	if matched > 0 {
+		if isVersioned {
+			v.setVersion(v.GetVersion() + 1)
+		}
+		return afterUpdate(ctx, model)
+	}
+
+	if isVersioned {
+		exists, err := r.Count(ctx, bson.M{"_id": model.documentID()})
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrVersionConflict
+		}
+	}
+
+	return ErrNotFound
+}
+
+// Upsert updates the active document matching the filter with the model, or
+// inserts the model when none matches, and returns the stored document and
+// whether it was inserted
+// It behaves like Repository.Upsert, hooks included; inserted documents also
+// receive the equality conditions of the filter, as MongoDB does
+func (r *MemoryRepository[T, PT]) Upsert(ctx context.Context, filter interface{}, model PT) (PT, bool, error) {
+	if r.data.indexErr != nil {
+		return nil, false, r.data.indexErr
+	}
+
+	if hasUpsertHooks(model) {
+		exists := false
+		if match := upsertMatch(filter, model); match != nil {
+			existing, err := r.withScope(scopeActive).Count(ctx, match)
+			if err != nil {
+				return nil, false, err
+			}
+			exists = existing > 0
+		}
+		hook := beforeInsert
+		if exists {
+			hook = beforeUpdate
+		}
+		if err := hook(ctx, model); err != nil {
+			return nil, false, err
+		}
+	}
+
+	match, update, err := prepareUpsert(ctx, model, filter)
+	if err != nil {
+		return nil, false, err
+	}
+	query, err := r.query(ctx, scopeActive, match)
+	if err != nil {
+		return nil, false, err
+	}
+	changes, err := normalizeDocument(update)
+	if err != nil {
+		return nil, false, err
+	}
+
+	raw, inserted, err := r.data.upsert(query, changes, r.keyOf)
+	if err != nil {
+		return nil, false, err
+	}
+
+	stored := PT(new(T))
+	if err := bson.Unmarshal(raw, stored); err != nil {
+		return nil, false, err
+	}
+	if err := afterFind(ctx, stored); err != nil {
+		return nil, false, err
+	}
+
+	after := afterUpdate
+	if inserted {
+		after = afterInsert
+	}
+	if err := after(ctx, model); err != nil {
+		return stored, inserted, err
+	}
+
+	return stored, inserted, nil
+}
+
+// UpdateMany applies the update to every document matching the filter
+// and returns the number of modified documents
+// For tenant models it returns ErrTenantUpdate when the update writes tenant_id
+// updated_at, updated_by for auditable models and the version for versioned
+// models are maintained as in Update
+// It returns a DuplicateKeyError when an updated document breaks a unique index
+func (r *MemoryRepository[T, PT]) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (int64, error) {
+	if err := checkTenantUpdate(PT(new(T)), update); err != nil {
+		return 0, err
+	}
+	uo := options.MergeUpdateOptions(opts...)
+	switch {
+	case uo.ArrayFilters != nil:
+		return 0, unsupportedOption("arrayFilters")
+	case uo.Collation != nil:
+		return 0, unsupportedOption("collation")
+	case uo.Let != nil:
+		return 0, unsupportedOption("let")
+	}
+
+	update, err := updateManyDocument(ctx, PT(new(T)), update)
+	if err != nil {
+		return 0, err
+	}
+	query, err := r.query(ctx, r.scope, filter)
+	if err != nil {
+		return 0, err
+	}
+	changes, err := normalizeDocument(update)
+	if err != nil {
+		return 0, err
+	}
+
+	matched, modified, err := r.data.update(query, changes, true)
+	if err != nil || matched > 0 || uo.Upsert == nil || !*uo.Upsert {
+		return modified, err
+	}
+
+	// Like MongoDB, an upsert matching nothing inserts one document seeded
+	// from the equality conditions of the filter
+
+	_, _, err = r.data.upsert(query, changes, r.keyOf)
+	return 0, err
+}
+
+// SoftDelete marks the document as deleted by setting its deleted_at timestamp
+// It returns ErrNotFound when the document does not exist or is already deleted
+func (r *MemoryRepository[T, PT]) SoftDelete(ctx context.Context, id string) error {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return err
+	}
+
+	model := PT(new(T))
+	if hasSoftDeleteHooks(model) {
+		if model, err = r.withScope(scopeActive).FindOne(ctx, bson.M{"_id": objID}); err != nil {
+			return err
+		}
+		if err := beforeSoftDelete(ctx, model); err != nil {
+			return err
+		}
+	}
+
+	setDeleteMeta(ctx, model)
+
+	set := bson.D{{Key: "deleted_at", Value: model.GetDeletedAt()}}
+	if actor, ok := ActorFromContext(ctx); ok && r.isAuditable() {
+		set = append(set, bson.E{Key: "deleted_by", Value: actor})
+	}
+	update := bson.D{{Key: "$set", Value: set}}
+	if r.isVersioned() {
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}})
+	}
+
+	matched, _, err := r.update(ctx, scopeActive, bson.M{"_id": objID}, update, false)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return ErrNotFound
+	}
+
+	return afterSoftDelete(ctx, model)
+}
+
+// Restore undoes a soft delete by unsetting deleted_at and setting updated_at
+// It returns ErrNotDeleted when the document exists but was never deleted, and
+// a DuplicateKeyError when an active document already holds a unique value
+func (r *MemoryRepository[T, PT]) Restore(ctx context.Context, id string) error {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return err
+	}
+
+	var meta BaseCollection
+	meta.ClearDeleteMeta()
+
+	set := bson.D{{Key: "updated_at", Value: meta.UpdatedAt}}
+	unset := bson.D{{Key: "deleted_at", Value: ""}}
+	if r.isAuditable() {
+		unset = append(unset, bson.E{Key: "deleted_by", Value: ""})
+		if actor, ok := ActorFromContext(ctx); ok {
+			set = append(set, bson.E{Key: "updated_by", Value: actor})
+		}
+	}
+	update := bson.D{{Key: "$set", Value: set}, {Key: "$unset", Value: unset}}
+	if r.isVersioned() {
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}})
+	}
+
+	filter := bson.M{"_id": objID}
+	matched, _, err := r.update(ctx, scopeOnlyDeleted, filter, update, false)
+	if err != nil || matched > 0 {
+		return err
+	}
+
+	active, err := r.withScope(scopeActive).Count(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrNotDeleted
+	}
+
+	return ErrNotFound
+}
+
+// HardDelete permanently removes the document regardless of its soft delete state
+// It returns the number of removed documents, or ErrNotFound when nothing was removed
+func (r *MemoryRepository[T, PT]) HardDelete(ctx context.Context, id string) (int64, error) {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	query, err := r.destructiveQuery(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := r.data.remove(query)
+	if err != nil {
+		return 0, err
+	}
+	if deleted == 0 {
+		return 0, ErrNotFound
+	}
+
+	return deleted, nil
+}
+
+// Purge permanently removes soft deleted documents whose deleted_at is older
+// than the retention window and returns the number of removed documents
+func (r *MemoryRepository[T, PT]) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	cutoff := timeNow().Add(-olderThan)
+
+	query, err := r.destructiveQuery(ctx, bson.M{"deleted_at": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+
+	return r.data.remove(query)
+}
+
+// query combines the filter with the scope and model conditions and
+// normalizes it for the matcher
+func (r *MemoryRepository[T, PT]) query(ctx context.Context, scope softDeleteScope, filter interface{}) (bson.D, error) {
+	scoped, err := scopedFilter(ctx, PT(new(T)), scope, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeDocument(scoped)
+}
+
+// destructiveQuery normalizes the filter of a hard delete or purge, which
+// reach every document of the tenant including expired ones
+func (r *MemoryRepository[T, PT]) destructiveQuery(ctx context.Context, filter interface{}) (bson.D, error) {
+	scoped, err := destructiveFilter(ctx, PT(new(T)), scopeWithDeleted, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeDocument(scoped)
+}
+
+// find returns the matching documents sorted, skipped and limited, decoded
+// into models with the AfterFind hook applied
+func (r *MemoryRepository[T, PT]) find(ctx context.Context, filter, sort interface{}, skip, limit int64) ([]PT, error) {
+	query, err := r.query(ctx, r.scope, filter)
+	if err != nil {
+		return nil, err
+	}
+	matched, err := r.data.matching(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if sort != nil {
+		spec, err := normalizeDocument(sort)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortStableFunc(matched, func(a, b memoryDoc) int {
+			return compareDocuments(a.doc, b.doc, spec)
+		})
+	}
+
+	if skip > 0 {
+		matched = matched[min(skip, int64(len(matched))):]
+	}
+	if limit < 0 {
+		limit = -limit
+	}
+	if limit > 0 && int64(len(matched)) > limit {
+		matched = matched[:limit]
+	}
+
+	models := make([]PT, 0, len(matched))
+	for _, stored := range matched {
+		model := PT(new(T))
+		if err := bson.Unmarshal(stored.raw, model); err != nil {
+			return nil, err
+		}
+		if err := afterFind(ctx, model); err != nil {
+			return nil, err
+		}
+		models = append(models, model)
+	}
+
+	return models, nil
+}
+
+// update applies the update to the first, or every, document matching the
+// filter in the scope and returns the matched and modified counts
+func (r *MemoryRepository[T, PT]) update(ctx context.Context, scope softDeleteScope, filter, update interface{}, many bool) (int64, int64, error) {
+	query, err := r.query(ctx, scope, filter)
+	if err != nil {
+		return 0, 0, err
+	}
+	changes, err := normalizeDocument(update)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return r.data.update(query, changes, many)
+}
+
+// keyOf returns the key a stored document is kept under
+func (r *MemoryRepository[T, PT]) keyOf(raw bson.Raw) (string, error) {
+	key := PT(new(T))
+	if err := bson.Unmarshal(raw, key); err != nil {
+		return "", err
+	}
+	return key.GetID(), nil
+}
+
+// isVersioned reports whether the repository model embeds VersionFields
+func (r *MemoryRepository[T, PT]) isVersioned() bool {
+	_, ok := any(PT(new(T))).(versioned)
+	return ok
+}
+
+// isAuditable reports whether the repository model embeds AuditFields
+func (r *MemoryRepository[T, PT]) isAuditable() bool {
+	_, ok := any(PT(new(T))).(auditable)
+	return ok
+}
+
+// insert stores a new document, enforcing _id and unique index constraints
+func (d *memoryData) insert(id string, raw bson.Raw) error {
+	doc, err := decodeDocument(raw)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.docs[id]; exists {
+		return &DuplicateKeyError{Field: "_id", Index: "_id_"}
+	}
+	if err := d.checkUnique(id, doc); err != nil {
+		return err
+	}
+
+	d.docs[id] = memoryDoc{id: id, raw: raw, doc: doc}
+	d.order = append(d.order, id)
+
+	return nil
+}
+
+// matching returns the documents matching the query in insertion order
+func (d *memoryData) matching(query bson.D) ([]memoryDoc, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.matchingLocked(query)
+}
+
+func (d *memoryData) matchingLocked(query bson.D) ([]memoryDoc, error) {
+	var docs []memoryDoc
+	for _, id := range d.order {
+		stored := d.docs[id]
+		ok, err := matchDocument(stored.doc, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			docs = append(docs, stored)
+		}
+	}
+	return docs, nil
+}
+
+// update applies the changes to the documents matching the query, stopping
+// after the first unless many is set
+// Like MongoDB, documents updated before a failing one keep their changes
+func (d *memoryData) update(query, changes bson.D, many bool) (matched, modified int64, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	docs, err := d.matchingLocked(query)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, stored := range docs {
+		matched++
+
+		current, err := decodeDocument(stored.raw)
+		if err != nil {
+			return matched, modified, err
+		}
+		next, err := applyUpdate(current, changes, false)
+		if err != nil {
+			return matched, modified, err
+		}
+		raw, err := bson.Marshal(next)
+		if err != nil {
+			return matched, modified, err
+		}
+
+		if !bytes.Equal(raw, stored.raw) {
+			if err := d.checkUnique(stored.id, next); err != nil {
+				return matched, modified, err
+			}
+			d.docs[stored.id] = memoryDoc{id: stored.id, raw: raw, doc: next}
+			modified++
+		}
+
+		if !many {
+			break
+		}
+	}
+
+	return matched, modified, nil
+}
+
+// upsert updates the first document matching the query, or inserts a new
+// one seeded from the equality conditions of the query, and returns the
+// stored document and whether it was inserted
+// keyOf returns the key of a new document
+func (d *memoryData) upsert(query, changes bson.D, keyOf func(bson.Raw) (string, error)) (bson.Raw, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	docs, err := d.matchingLocked(query)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var current bson.D
+	if len(docs) > 0 {
+		if current, err = decodeDocument(docs[0].raw); err != nil {
+			return nil, false, err
+		}
+	} else {
+		current = upsertSeed(query)
+	}
+
+	inserting := len(docs) == 0
+	next, err := applyUpdate(current, changes, inserting)
+	if err != nil {
+		return nil, false, err
+	}
+	if inserting && sortValue(next, "_id") == nil {
+		// MongoDB generates an ObjectID when neither the query nor the
+		// update sets _id
+		next = append(bson.D{{Key: "_id", Value: primitive.NewObjectID()}}, next...)
+	}
+	raw, err := bson.Marshal(next)
+	if err != nil {
+		return nil, false, err
+	}
+
+	id := ""
+	if inserting {
+		if id, err = keyOf(raw); err != nil {
+			return nil, false, err
+		}
+		if _, exists := d.docs[id]; exists {
+			return nil, false, &DuplicateKeyError{Field: "_id", Index: "_id_"}
+		}
+	} else {
+		id = docs[0].id
+	}
+	if err := d.checkUnique(id, next); err != nil {
+		return nil, false, err
+	}
+
+	d.docs[id] = memoryDoc{id: id, raw: raw, doc: next}
+	if inserting {
+		d.order = append(d.order, id)
+	}
+
+	return raw, inserting, nil
+}
+
+// remove deletes every document matching the query
+func (d *memoryData) remove(query bson.D) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	docs, err := d.matchingLocked(query)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := make(map[string]bool, len(docs))
+	for _, stored := range docs {
+		delete(d.docs, stored.id)
+		removed[stored.id] = true
+	}
+	order := d.order[:0]
+	for _, id := range d.order {
+		if !removed[id] {
+			order = append(order, id)
+		}
+	}
+	d.order = order
+
+	return int64(len(docs)), nil
+}
+
+// checkUnique returns a DuplicateKeyError when doc holds the same key as
+// another document for any unique index both are part of
+func (d *memoryData) checkUnique(id string, doc bson.D) error {
+	for _, spec := range d.unique {
+		ok, err := indexCovers(spec, doc)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		key := indexKey(spec, doc)
+		for _, otherID := range d.order {
+			if otherID == id {
+				continue
+			}
+			other := d.docs[otherID].doc
+			covered, err := indexCovers(spec, other)
+			if err != nil {
+				return err
+			}
+			if covered && compareValues(key, indexKey(spec, other)) == 0 {
+				fields := make([]string, len(spec.Keys))
+				for i, k := range spec.Keys {
+					fields[i] = k.Key
+				}
+				return &DuplicateKeyError{Field: strings.Join(fields, ","), Index: spec.Name}
+			}
+		}
+	}
+
+	return nil
+}
+
+// indexCovers reports whether the document is part of the index, taking
+// its partial filter into account
+func indexCovers(spec IndexSpec, doc bson.D) (bool, error) {
+	partial := spec.partialFilter()
+	if len(partial) == 0 {
+		return true, nil
+	}
+
+	filter, err := normalizeDocument(partial)
+	if err != nil {
+		return false, err
+	}
+	return matchDocument(doc, filter)
+}
+
+// indexKey returns the values a document holds for the index keys,
+// with nil for missing fields
+func indexKey(spec IndexSpec, doc bson.D) bson.A {
+	key := make(bson.A, len(spec.Keys))
+	for i, k := range spec.Keys {
+		key[i] = sortValue(doc, k.Key)
+	}
+	return key
+}
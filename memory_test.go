@@ -0,0 +1,388 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	_ Store[TestUser, *TestUser] = (*Repository[TestUser, *TestUser])(nil)
+	_ Store[TestUser, *TestUser] = (*MemoryRepository[TestUser, *TestUser])(nil)
+)
+
+// seedUsers creates users one minute apart and returns them in creation order
+func seedUsers(t *testing.T, repo Store[TestUser, *TestUser], names ...string) []*TestUser {
+	t.Helper()
+
+	clock := useFakeClock(t)
+	users := make([]*TestUser, len(names))
+	for i, name := range names {
+		users[i] = &TestUser{Name: name, Email: name + "@example.com"}
+		if err := repo.Create(context.Background(), users[i]); err != nil {
+			t.Fatalf("Create returned error: %v", err)
+		}
+		clock.Advance(time.Minute)
+	}
+	return users
+}
+
+func TestMemoryRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestUser]()
+
+	user := &TestUser{Name: "John Doe", Email: "john@example.com"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if user.Oid.IsZero() || user.CreatedAt.IsZero() {
+		t.Fatal("Expected insert metadata to be set by Create")
+	}
+
+	found, err := repo.FindByID(ctx, user.GetID())
+	if err != nil {
+		t.Fatalf("FindByID returned error: %v", err)
+	}
+	if found.Name != "John Doe" || !found.CreatedAt.Equal(user.CreatedAt.Truncate(time.Millisecond)) {
+		t.Errorf("Unexpected document %+v", found)
+	}
+
+	found.Name = "changed without saving"
+	again, _ := repo.FindByID(ctx, user.GetID())
+	if again.Name != "John Doe" {
+		t.Error("Expected stored documents to be isolated from returned models")
+	}
+
+	user.Name = "John Smith"
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	updated, _ := repo.FindOne(ctx, bson.M{"name": "John Smith"})
+	if updated == nil || updated.UpdatedAt == nil {
+		t.Fatal("Expected the update to be stored with updated_at")
+	}
+
+	if _, err := repo.FindByID(ctx, "bad"); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+	if err := repo.Update(ctx, &TestUser{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown document, got %v", err)
+	}
+}
+
+func TestMemoryRepositorySoftDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestUser]()
+	users := seedUsers(t, repo, "alice", "bob")
+	id := users[0].GetID()
+
+	if err := repo.SoftDelete(ctx, id); err != nil {
+		t.Fatalf("SoftDelete returned error: %v", err)
+	}
+	if err := repo.SoftDelete(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an already deleted document, got %v", err)
+	}
+
+	if _, err := repo.FindByID(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the deleted document to be hidden, got %v", err)
+	}
+	if n, _ := repo.Count(ctx, nil); n != 1 {
+		t.Errorf("Expected 1 active document, got %d", n)
+	}
+	if n, _ := repo.WithDeleted().Count(ctx, nil); n != 2 {
+		t.Errorf("Expected 2 documents with deleted, got %d", n)
+	}
+	deleted, _ := repo.FindDeleted(ctx)
+	if len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Fatalf("Expected 1 deleted document, got %v", deleted)
+	}
+
+	if err := repo.Restore(ctx, users[1].GetID()); !errors.Is(err, ErrNotDeleted) {
+		t.Errorf("Expected ErrNotDeleted, got %v", err)
+	}
+	if err := repo.Restore(ctx, id); err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	restored, err := repo.FindByID(ctx, id)
+	if err != nil || restored.DeletedAt != nil {
+		t.Fatalf("Expected the document to be active again, got %v", err)
+	}
+
+	if err := repo.SoftDelete(ctx, id); err != nil {
+		t.Fatalf("SoftDelete returned error: %v", err)
+	}
+	useFakeClock(t).Advance(48 * time.Hour)
+	if n, _ := repo.Purge(ctx, 24*time.Hour); n != 1 {
+		t.Errorf("Expected 1 purged document, got %d", n)
+	}
+	if n, _ := repo.HardDelete(ctx, users[1].GetID()); n != 1 {
+		t.Errorf("Expected 1 hard deleted document, got %d", n)
+	}
+	if n, _ := repo.WithDeleted().Count(ctx, nil); n != 0 {
+		t.Errorf("Expected no documents left, got %d", n)
+	}
+}
+
+func TestMemoryRepositoryVersioned(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestAccount]()
+
+	account := &TestAccount{Balance: 100}
+	if err := repo.Create(ctx, account); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	stale, _ := repo.FindByID(ctx, account.GetID())
+
+	account.Balance = 150
+	if err := repo.Update(ctx, account); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if account.GetVersion() != 2 {
+		t.Errorf("Expected version 2, got %d", account.GetVersion())
+	}
+
+	stale.Balance = 50
+	if err := repo.Update(ctx, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryQuery(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestUser]()
+	seedUsers(t, repo, "carol", "alice", "dave", "bob", "erin")
+
+	sorted, err := repo.Find(ctx, bson.M{"name": bson.M{"$ne": "erin"}}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetSkip(1).SetLimit(2))
+	if err != nil {
+		t.Fatalf("Find returned error: %v", err)
+	}
+	if len(sorted) != 2 || sorted[0].Name != "bob" || sorted[1].Name != "carol" {
+		t.Errorf("Expected bob and carol, got %v", sorted)
+	}
+
+	first, err := repo.FindOne(ctx, nil, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil || first.Name != "erin" {
+		t.Errorf("Expected the newest user, got %v (%v)", first, err)
+	}
+
+	modified, err := repo.UpdateMany(ctx, Field("name").In("alice", "bob"), bson.M{"$set": bson.M{"email": "team@example.com"}})
+	if err != nil || modified != 2 {
+		t.Errorf("Expected 2 modified documents, got %d (%v)", modified, err)
+	}
+	if n, _ := repo.Count(ctx, bson.M{"email": "team@example.com"}); n != 2 {
+		t.Errorf("Expected 2 updated documents, got %d", n)
+	}
+}
+
+func TestMemoryRepositoryPagination(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestUser]()
+	seedUsers(t, repo, "a", "b", "c", "d", "e")
+
+	var names []string
+	req := PageRequest{Limit: 2, IncludeTotal: true}
+	for {
+		page, err := repo.FindPage(ctx, nil, req)
+		if err != nil {
+			t.Fatalf("FindPage returned error: %v", err)
+		}
+		if *page.Total != 5 {
+			t.Errorf("Expected a total of 5, got %d", *page.Total)
+		}
+		for _, u := range page.Items {
+			names = append(names, u.Name)
+		}
+		if page.Next == "" {
+			break
+		}
+		req.After = page.Next
+	}
+	if got := len(names); got != 5 || names[0] != "a" || names[4] != "e" {
+		t.Errorf("Expected a through e in order, got %v", names)
+	}
+
+	page, err := repo.FindOffset(ctx, nil, OffsetRequest{Page: 2, PerPage: 2})
+	if err != nil {
+		t.Fatalf("FindOffset returned error: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Name != "c" || page.Next != "3" || page.Prev != "1" {
+		t.Errorf("Unexpected offset page %+v", page)
+	}
+}
+
+func TestMemoryRepositoryUnique(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestArticle]()
+
+	first := &TestArticle{Slug: "hello", Title: "Hello"}
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	err := repo.Create(ctx, &TestArticle{Slug: "hello", Title: "Again"})
+	var dup *DuplicateKeyError
+	if !errors.As(err, &dup) || dup.Field != "slug" || dup.Index != "slug_1" {
+		t.Fatalf("Expected a duplicate slug, got %v", err)
+	}
+
+	second := &TestArticle{Slug: "world"}
+	if err := repo.Create(ctx, second); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	second.Slug = "hello"
+	if err := repo.Update(ctx, second); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("Expected ErrDuplicateKey on update, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryTenant(t *testing.T) {
+	repo := NewMemoryRepository[TestProject]()
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+
+	project := &TestProject{Name: "Rocket"}
+	if err := repo.Create(acme, project); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if project.TenantID != "acme" {
+		t.Errorf("Expected the tenant to be stamped, got %q", project.TenantID)
+	}
+
+	if _, err := repo.FindByID(globex, project.GetID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another tenant not to see the project, got %v", err)
+	}
+	if _, err := repo.FindAll(context.Background()); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant, got %v", err)
+	}
+	if _, err := repo.UpdateMany(acme, bson.M{}, bson.M{"$set": bson.M{"tenant_id": "globex"}}); !errors.Is(err, ErrTenantUpdate) {
+		t.Errorf("Expected ErrTenantUpdate, got %v", err)
+	}
+	if _, err := repo.FindByID(acme, project.GetID()); err != nil {
+		t.Errorf("Expected the project to stay with its tenant, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryUpsert(t *testing.T) {
+	ctx := context.Background()
+	clock := useFakeClock(t)
+	repo := NewMemoryRepository[TestUser]()
+
+	filter := bson.M{"email": "john@example.com"}
+	first, inserted, err := repo.Upsert(ctx, filter, &TestUser{Name: "John Doe", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Upsert returned error: %v", err)
+	}
+	if !inserted || first.Name != "John Doe" {
+		t.Errorf("Expected the user to be inserted, got %+v (inserted %v)", first, inserted)
+	}
+	if n, _ := repo.Count(ctx, bson.M{"deleted_at": bson.M{"$exists": false}}); n != 1 {
+		t.Error("Expected the inserted document to be stored without deleted_at")
+	}
+
+	clock.Advance(time.Hour)
+	second, inserted, err := repo.Upsert(ctx, filter, &TestUser{Name: "John Smith", Email: "john@example.com"})
+	if err != nil {
+		t.Fatalf("Upsert returned error: %v", err)
+	}
+	if inserted {
+		t.Error("Expected the second upsert to update")
+	}
+	if second.GetID() != first.GetID() || second.Name != "John Smith" {
+		t.Errorf("Expected the same document to be updated, got %+v", second)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("Expected created_at to be kept, got %v and %v", first.CreatedAt, second.CreatedAt)
+	}
+	if second.UpdatedAt == nil || !second.UpdatedAt.After(first.CreatedAt) {
+		t.Error("Expected updated_at to be set by the update")
+	}
+
+	moved, inserted, err := repo.Upsert(ctx, filter, &TestUser{Name: "John Smith", Email: "smith@example.com"})
+	if err != nil || inserted {
+		t.Fatalf("Expected the upsert to update, got %v (%v)", inserted, err)
+	}
+	if moved.GetID() != first.GetID() || moved.Email != "smith@example.com" {
+		t.Errorf("Expected the document written under the new email, got %+v", moved)
+	}
+
+	countries := NewMemoryRepository[TestCountry]()
+	th, inserted, err := countries.Upsert(ctx, nil, &TestCountry{Base: Base[StringID]{ID: "TH"}, Name: "Thailand"})
+	if err != nil || !inserted {
+		t.Fatalf("Expected the country to be inserted, got %v (%v)", inserted, err)
+	}
+	if th.CreatedAt.IsZero() {
+		t.Error("Expected created_at to be set for a model with a natural key")
+	}
+	if _, _, err := countries.Upsert(ctx, nil, &TestCountry{}); !errors.Is(err, ErrMissingID) {
+		t.Errorf("Expected ErrMissingID, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryUpdateManyOptions(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestUser]()
+	seedUsers(t, repo, "john")
+
+	upsert := options.Update().SetUpsert(true)
+	modified, err := repo.UpdateMany(ctx, bson.M{"name": "john"}, bson.M{"$set": bson.M{"email": "john@example.org"}}, upsert)
+	if err != nil {
+		t.Fatalf("UpdateMany returned error: %v", err)
+	}
+	if modified != 1 {
+		t.Errorf("Expected the existing document to be modified, got %d", modified)
+	}
+
+	if _, err := repo.UpdateMany(ctx, bson.M{"name": "jane"}, bson.M{"$set": bson.M{"email": "jane@example.com"}}, upsert); err != nil {
+		t.Fatalf("UpdateMany returned error: %v", err)
+	}
+	jane, err := repo.FindOne(ctx, bson.M{"name": "jane"})
+	if err != nil {
+		t.Fatalf("Expected the upsert to insert jane, got %v", err)
+	}
+	if jane.Email != "jane@example.com" || jane.Oid.IsZero() {
+		t.Errorf("Expected an inserted document with an _id and the update applied, got %+v", jane)
+	}
+	if n, _ := repo.Count(ctx, bson.M{}); n != 2 {
+		t.Errorf("Expected 2 documents, got %d", n)
+	}
+
+	collation := options.Update().SetCollation(&options.Collation{Locale: "en"})
+	if _, err := repo.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"name": "x"}}, collation); err == nil || !strings.Contains(err.Error(), "collation") {
+		t.Errorf("Expected an unsupported collation error, got %v", err)
+	}
+}
+
+func TestMemoryRepositoryUpsertHooks(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestMember]()
+	filter := bson.M{"email": "john@example.com"}
+
+	created := &TestMember{Email: "John@Example.com"}
+	if _, _, err := repo.Upsert(ctx, filter, created); err != nil {
+		t.Fatalf("Upsert returned error: %v", err)
+	}
+	if got := strings.Join(created.Calls, ","); got != "BeforeInsert,AfterInsert" {
+		t.Errorf("Expected insert hooks, got %s", got)
+	}
+
+	updated := &TestMember{Email: "john@example.com"}
+	if _, _, err := repo.Upsert(ctx, filter, updated); err != nil {
+		t.Fatalf("Upsert returned error: %v", err)
+	}
+	if got := strings.Join(updated.Calls, ","); got != "BeforeUpdate,AfterUpdate" {
+		t.Errorf("Expected update hooks, got %s", got)
+	}
+
+	rejected := &TestMember{Email: "jane@example.com", Reject: "BeforeInsert"}
+	if _, _, err := repo.Upsert(ctx, bson.M{"email": "jane@example.com"}, rejected); !errors.Is(err, errHookRejected) {
+		t.Errorf("Expected the before hook to abort the upsert, got %v", err)
+	}
+	if n, _ := repo.Count(ctx, bson.M{}); n != 1 {
+		t.Errorf("Expected one stored member, got %d", n)
+	}
+}
@@ -0,0 +1,552 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultMigrationsCollection is the ledger collection used when none is configured
+const DefaultMigrationsCollection = "migrations"
+
+// DefaultMigrationLockTimeout is how long a lock is honored before another
+// instance may take it over, for example after a crash
+const DefaultMigrationLockTimeout = 15 * time.Minute
+
+// MigrationFunc changes the database in one direction of a migration
+type MigrationFunc func(ctx context.Context, db *mongo.Database) error
+
+// Migration is a single versioned schema change
+// Versions are applied in ascending order; a timestamp such as 20240601120000
+// keeps them unique across branches. Down is optional
+// Migrations do not run in a transaction, so Up and Down should be safe to
+// run again after a partial failure
+type Migration struct {
+	Version     int64
+	Description string
+	Up          MigrationFunc
+	Down        MigrationFunc
+}
+
+// MigrationStatus reports whether a migration has been applied
+// Unknown marks a version found in the ledger without a registered migration
+type MigrationStatus struct {
+	Version     int64      `json:"version"`
+	Description string     `json:"description"`
+	Applied     bool       `json:"applied"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	Unknown     bool       `json:"unknown,omitempty"`
+}
+
+// migrationRecord is a ledger entry in the migrations collection
+type migrationRecord struct {
+	Version     int64     `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// migrationLock is the single lock document in the lock collection
+type migrationLock struct {
+	ID        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	LockedAt  time.Time `bson:"locked_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+const migrationLockID = "migrate"
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[int64]Migration)
+)
+
+// RegisterMigration adds a migration to the package registry used by
+// NewMigrator. It is meant to be called from init functions and panics
+// when the version is already registered
+func RegisterMigration(m Migration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if m.Up == nil {
+		panic(fmt.Sprintf("basemodel: migration %d has no Up function", m.Version))
+	}
+	if _, dup := registry[m.Version]; dup {
+		panic(fmt.Sprintf("basemodel: migration %d registered twice", m.Version))
+	}
+	registry[m.Version] = m
+}
+
+// RegisteredMigrations returns the registered migrations in version order
+func RegisteredMigrations() []Migration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	migrations := make([]Migration, 0, len(registry))
+	for _, m := range registry {
+		migrations = append(migrations, m)
+	}
+	sortMigrations(migrations)
+
+	return migrations
+}
+
+func sortMigrations(migrations []Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+// Migrator applies migrations and records them in the ledger collection
+// A lock document in "<collection>_lock" keeps concurrent instances from
+// migrating at the same time
+type Migrator struct {
+	db          *mongo.Database
+	ledger      *mongo.Collection
+	lock        *mongo.Collection
+	migrations  []Migration
+	lockTimeout time.Duration
+	dryRun      bool
+}
+
+// MigratorOption configures optional migrator behavior
+type MigratorOption func(*migratorConfig)
+
+// migratorConfig holds the settings applied by MigratorOption
+type migratorConfig struct {
+	collection  string
+	lockTimeout time.Duration
+	migrations  []Migration
+}
+
+// WithMigrationsCollection overrides the ledger collection name
+func WithMigrationsCollection(name string) MigratorOption {
+	return func(c *migratorConfig) {
+		c.collection = name
+	}
+}
+
+// WithMigrationLockTimeout overrides how long a lock is honored
+// A running migrator extends its lock every third of the timeout
+func WithMigrationLockTimeout(d time.Duration) MigratorOption {
+	return func(c *migratorConfig) {
+		c.lockTimeout = d
+	}
+}
+
+// WithMigrations uses the given migrations instead of the package registry
+func WithMigrations(migrations ...Migration) MigratorOption {
+	return func(c *migratorConfig) {
+		c.migrations = append([]Migration{}, migrations...)
+	}
+}
+
+// NewMigrator creates a migrator for the database using the registered migrations
+//
+//	m := basemodel.NewMigrator(db)
+//	if _, err := m.Up(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+func NewMigrator(db *mongo.Database, opts ...MigratorOption) *Migrator {
+	cfg := migratorConfig{
+		collection:  DefaultMigrationsCollection,
+		lockTimeout: DefaultMigrationLockTimeout,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	migrations := cfg.migrations
+	if migrations == nil {
+		migrations = RegisteredMigrations()
+	} else {
+		sortMigrations(migrations)
+	}
+
+	return &Migrator{
+		db:          db,
+		ledger:      db.Collection(cfg.collection),
+		lock:        db.Collection(cfg.collection + "_lock"),
+		migrations:  migrations,
+		lockTimeout: cfg.lockTimeout,
+	}
+}
+
+// DryRun returns a copy of the migrator that reports what Up and Down would
+// do without taking the lock, running migrations or writing the ledger
+func (m *Migrator) DryRun() *Migrator {
+	dry := *m
+	dry.dryRun = true
+	return &dry
+}
+
+// Status lists every registered migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		status := MigrationStatus{Version: mig.Version, Description: mig.Description}
+		if rec, ok := applied[mig.Version]; ok {
+			appliedAt := rec.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, rec := range applied {
+		if known[rec.Version] {
+			continue
+		}
+		appliedAt := rec.AppliedAt
+		statuses = append(statuses, MigrationStatus{
+			Version:     rec.Version,
+			Description: rec.Description,
+			Applied:     true,
+			AppliedAt:   &appliedAt,
+			Unknown:     true,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns them
+// It stops at the first failure; migrations applied before it stay recorded
+// It returns ErrMigrationLocked when another instance holds the lock
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, release, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if m.dryRun {
+			done = append(done, mig)
+			continue
+		}
+
+		if err := lockLost(ctx); err != nil {
+			return done, err
+		}
+		if err := mig.Up(ctx, m.db); err != nil {
+			if lost := lockLost(ctx); lost != nil {
+				err = lost
+			}
+			return done, fmt.Errorf("basemodel: migration %d up: %w", mig.Version, err)
+		}
+		rec := migrationRecord{Version: mig.Version, Description: mig.Description, AppliedAt: timeNow()}
+		if _, err := m.ledger.InsertOne(ctx, rec); err != nil {
+			return done, fmt.Errorf("basemodel: record migration %d: %w", mig.Version, err)
+		}
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first
+// It fails before changing anything when one of them has no Down function
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, release, err := m.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(targets) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == nil {
+			return nil, fmt.Errorf("basemodel: migration %d has no Down function", mig.Version)
+		}
+		targets = append(targets, mig)
+	}
+	if m.dryRun {
+		return targets, nil
+	}
+
+	var done []Migration
+	for _, mig := range targets {
+		if err := lockLost(ctx); err != nil {
+			return done, err
+		}
+		if err := mig.Down(ctx, m.db); err != nil {
+			if lost := lockLost(ctx); lost != nil {
+				err = lost
+			}
+			return done, fmt.Errorf("basemodel: migration %d down: %w", mig.Version, err)
+		}
+		if _, err := m.ledger.DeleteOne(ctx, bson.M{"_id": mig.Version}); err != nil {
+			return done, fmt.Errorf("basemodel: record migration %d: %w", mig.Version, err)
+		}
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+// validate rejects duplicate versions and migrations without an Up function
+func (m *Migrator) validate() error {
+	for i, mig := range m.migrations {
+		if mig.Up == nil {
+			return fmt.Errorf("basemodel: migration %d has no Up function", mig.Version)
+		}
+		if i > 0 && m.migrations[i-1].Version == mig.Version {
+			return fmt.Errorf("basemodel: migration %d registered twice", mig.Version)
+		}
+	}
+	return nil
+}
+
+// applied reads the ledger keyed by version
+func (m *Migrator) applied(ctx context.Context) (map[int64]migrationRecord, error) {
+	cursor, err := m.ledger.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []migrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]migrationRecord, len(records))
+	for _, rec := range records {
+		applied[rec.Version] = rec
+	}
+
+	return applied, nil
+}
+
+// acquire takes the migration lock, taking over an expired one, and returns
+// the context of the run and the function that releases the lock
+// A heartbeat extends the lock while the run holds it; when another instance
+// has taken it over the context is cancelled with ErrMigrationLocked
+// Dry runs never lock
+func (m *Migrator) acquire(ctx context.Context) (context.Context, func(), error) {
+	if m.dryRun {
+		return ctx, func() {}, nil
+	}
+
+	now := timeNow()
+	lock := migrationLock{
+		ID:        migrationLockID,
+		Owner:     primitive.NewObjectID().Hex(),
+		LockedAt:  now,
+		ExpiresAt: now.Add(m.lockTimeout),
+	}
+
+	_, err := m.lock.InsertOne(ctx, lock)
+	if mongo.IsDuplicateKeyError(err) {
+		filter := bson.M{"_id": migrationLockID, "expires_at": bson.M{"$lt": now}}
+		update := bson.M{"$set": bson.M{
+			"owner":      lock.Owner,
+			"locked_at":  lock.LockedAt,
+			"expires_at": lock.ExpiresAt,
+		}}
+		result, updateErr := m.lock.UpdateOne(ctx, filter, update)
+		if updateErr != nil {
+			return nil, nil, updateErr
+		}
+		if result.MatchedCount == 0 {
+			return nil, nil, ErrMigrationLocked
+		}
+		err = nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	runCtx, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.heartbeat(runCtx, lock.Owner, stop, cancel)
+	}()
+
+	return runCtx, func() {
+		close(stop)
+		<-stopped
+		cancel(nil)
+		// Release with a fresh context so a cancelled run still unlocks
+		_, _ = m.lock.DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": migrationLockID, "owner": lock.Owner})
+	}, nil
+}
+
+// heartbeat extends the lock every third of the lock timeout until stop is
+// closed, and cancels the run with ErrMigrationLocked once the lock belongs
+// to another owner. Failed refreshes are retried on the next tick
+func (m *Migrator) heartbeat(ctx context.Context, owner string, stop <-chan struct{}, cancel context.CancelCauseFunc) {
+	interval := m.lockTimeout / 3
+	if interval <= 0 {
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		}
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			filter := bson.M{"_id": migrationLockID, "owner": owner}
+			update := bson.M{"$set": bson.M{"expires_at": timeNow().Add(m.lockTimeout)}}
+			result, err := m.lock.UpdateOne(ctx, filter, update)
+			if err != nil {
+				continue
+			}
+			if result.MatchedCount == 0 {
+				cancel(ErrMigrationLocked)
+				return
+			}
+		}
+	}
+}
+
+// lockLost returns ErrMigrationLocked when the run lost its lock
+func lockLost(ctx context.Context) error {
+	if errors.Is(context.Cause(ctx), ErrMigrationLocked) {
+		return ErrMigrationLocked
+	}
+	return nil
+}
+
+// Run executes a migration command, for use from a small CLI
+//
+//	[-dry-run] up          apply every pending migration
+//	[-dry-run] down [N]    roll back the last N migrations (default 1)
+//	status                 list migrations and when they were applied
+func (m *Migrator) Run(ctx context.Context, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	dryRun := fs.Bool("dry-run", false, "report pending changes without applying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	migrator := m
+	if *dryRun {
+		migrator = m.DryRun()
+	}
+	verb := "applied"
+	if *dryRun {
+		verb = "would apply"
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		done, err := migrator.Up(ctx)
+		printMigrations(out, verb, done)
+		return err
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			n, err := strconv.Atoi(fs.Arg(1))
+			if err != nil || n < 1 {
+				return fmt.Errorf("basemodel: invalid number of steps %q", fs.Arg(1))
+			}
+			steps = n
+		}
+		if *dryRun {
+			verb = "would roll back"
+		} else {
+			verb = "rolled back"
+		}
+		done, err := migrator.Down(ctx, steps)
+		printMigrations(out, verb, done)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		printStatus(out, statuses)
+		return nil
+	case "":
+		return errors.New("basemodel: missing migrate command: up, down or status")
+	default:
+		return fmt.Errorf("basemodel: unknown migrate command %q", fs.Arg(0))
+	}
+}
+
+func printMigrations(out io.Writer, verb string, migrations []Migration) {
+	if len(migrations) == 0 {
+		fmt.Fprintln(out, "nothing to do")
+		return
+	}
+	for _, mig := range migrations {
+		fmt.Fprintf(out, "%s %d %s\n", verb, mig.Version, mig.Description)
+	}
+}
+
+func printStatus(out io.Writer, statuses []MigrationStatus) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED AT\tDESCRIPTION")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		desc := s.Description
+		if s.Unknown {
+			desc += " (not registered)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, appliedAt, desc)
+	}
+	w.Flush()
+}
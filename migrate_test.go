@@ -0,0 +1,254 @@
+package basemodel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// testMigrations returns two migrations that record which functions ran
+func testMigrations(ran *[]string) []Migration {
+	step := func(name string) MigrationFunc {
+		return func(ctx context.Context, db *mongo.Database) error {
+			*ran = append(*ran, name)
+			return nil
+		}
+	}
+	return []Migration{
+		{Version: 2, Description: "add status", Up: step("up 2"), Down: step("down 2")},
+		{Version: 1, Description: "backfill email", Up: step("up 1"), Down: step("down 1")},
+	}
+}
+
+// ledgerResponse returns the ledger as the server would for the given versions
+func ledgerResponse(versions ...int64) bson.D {
+	docs := make([]bson.D, len(versions))
+	for i, v := range versions {
+		docs[i] = bson.D{
+			{Key: "_id", Value: v},
+			{Key: "description", Value: "applied"},
+			{Key: "applied_at", Value: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		}
+	}
+	return mtest.CreateCursorResponse(0, "db.migrations", mtest.FirstBatch, docs...)
+}
+
+func TestMigratorStatus(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("reports applied, pending and unknown versions", func(mt *mtest.T) {
+		var ran []string
+		m := NewMigrator(mt.DB, WithMigrations(testMigrations(&ran)...))
+		mt.AddMockResponses(ledgerResponse(1, 7))
+
+		statuses, err := m.Status(context.Background())
+		if err != nil {
+			mt.Fatalf("Status returned error: %v", err)
+		}
+		if len(statuses) != 3 {
+			mt.Fatalf("Expected 3 statuses, got %d", len(statuses))
+		}
+		if !statuses[0].Applied || statuses[0].AppliedAt == nil {
+			mt.Error("Expected version 1 to be applied")
+		}
+		if statuses[1].Applied {
+			mt.Error("Expected version 2 to be pending")
+		}
+		if !statuses[2].Unknown || statuses[2].Version != 7 {
+			mt.Errorf("Expected version 7 to be reported as unknown, got %+v", statuses[2])
+		}
+	})
+}
+
+func TestMigratorUp(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("applies pending migrations and records them", func(mt *mtest.T) {
+		var ran []string
+		m := NewMigrator(mt.DB, WithMigrations(testMigrations(&ran)...))
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			ledgerResponse(1),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		done, err := m.Up(context.Background())
+		if err != nil {
+			mt.Fatalf("Up returned error: %v", err)
+		}
+		if len(done) != 1 || done[0].Version != 2 {
+			mt.Fatalf("Expected only version 2 to be applied, got %v", done)
+		}
+		if len(ran) != 1 || ran[0] != "up 2" {
+			mt.Errorf("Expected only up 2 to run, got %v", ran)
+		}
+
+		var commands []string
+		for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+			commands = append(commands, e.CommandName+" "+e.Command.Lookup(e.CommandName).StringValue())
+		}
+		expected := []string{"insert migrations_lock", "find migrations", "insert migrations", "delete migrations_lock"}
+		if strings.Join(commands, ",") != strings.Join(expected, ",") {
+			mt.Errorf("Expected commands %v, got %v", expected, commands)
+		}
+	})
+
+	mt.Run("dry run does not lock or apply", func(mt *mtest.T) {
+		var ran []string
+		m := NewMigrator(mt.DB, WithMigrations(testMigrations(&ran)...)).DryRun()
+		mt.AddMockResponses(ledgerResponse())
+
+		done, err := m.Up(context.Background())
+		if err != nil {
+			mt.Fatalf("Up returned error: %v", err)
+		}
+		if len(done) != 2 || done[0].Version != 1 {
+			mt.Errorf("Expected versions 1 and 2 to be reported, got %v", done)
+		}
+		if len(ran) != 0 {
+			mt.Errorf("Expected no migration to run, got %v", ran)
+		}
+	})
+
+	mt.Run("fails when another instance takes over the lock", func(mt *mtest.T) {
+		var ran []string
+		slow := Migration{Version: 2, Description: "slow backfill", Up: func(ctx context.Context, db *mongo.Database) error {
+			ran = append(ran, "up 2")
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		}}
+		m := NewMigrator(mt.DB, WithMigrations(slow), WithMigrationLockTimeout(30*time.Millisecond))
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			ledgerResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+		)
+
+		done, err := m.Up(context.Background())
+		if !errors.Is(err, ErrMigrationLocked) {
+			mt.Fatalf("Expected ErrMigrationLocked, got %v", err)
+		}
+		if len(done) != 0 || len(ran) != 1 {
+			mt.Errorf("Expected the migration to be interrupted and not recorded, got %v", done)
+		}
+
+		var commands []string
+		for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+			commands = append(commands, e.CommandName+" "+e.Command.Lookup(e.CommandName).StringValue())
+		}
+		expected := []string{"insert migrations_lock", "find migrations", "update migrations_lock", "delete migrations_lock"}
+		if strings.Join(commands, ",") != strings.Join(expected, ",") {
+			mt.Errorf("Expected commands %v, got %v", expected, commands)
+		}
+	})
+
+	mt.Run("locked by another instance", func(mt *mtest.T) {
+		var ran []string
+		m := NewMigrator(mt.DB, WithMigrations(testMigrations(&ran)...))
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Code: 11000, Message: "E11000 duplicate key error"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+		)
+
+		if _, err := m.Up(context.Background()); !errors.Is(err, ErrMigrationLocked) {
+			mt.Errorf("Expected ErrMigrationLocked, got %v", err)
+		}
+		if len(ran) != 0 {
+			mt.Errorf("Expected no migration to run, got %v", ran)
+		}
+	})
+}
+
+func TestMigratorDown(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("rolls back the newest migration", func(mt *mtest.T) {
+		var ran []string
+		m := NewMigrator(mt.DB, WithMigrations(testMigrations(&ran)...))
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			ledgerResponse(1, 2),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		done, err := m.Down(context.Background(), 1)
+		if err != nil {
+			mt.Fatalf("Down returned error: %v", err)
+		}
+		if len(done) != 1 || done[0].Version != 2 {
+			mt.Errorf("Expected version 2 to be rolled back, got %v", done)
+		}
+		if len(ran) != 1 || ran[0] != "down 2" {
+			mt.Errorf("Expected only down 2 to run, got %v", ran)
+		}
+	})
+
+	mt.Run("requires a down function", func(mt *mtest.T) {
+		m := NewMigrator(mt.DB, WithMigrations(Migration{
+			Version: 1,
+			Up:      func(ctx context.Context, db *mongo.Database) error { return nil },
+		}))
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			ledgerResponse(1),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		if _, err := m.Down(context.Background(), 1); err == nil {
+			mt.Error("Expected an error for a migration without Down")
+		}
+	})
+}
+
+func TestMigratorRun(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("status", func(mt *mtest.T) {
+		var ran []string
+		m := NewMigrator(mt.DB, WithMigrations(testMigrations(&ran)...))
+		mt.AddMockResponses(ledgerResponse(1))
+
+		var out bytes.Buffer
+		if err := m.Run(context.Background(), []string{"status"}, &out); err != nil {
+			mt.Fatalf("Run returned error: %v", err)
+		}
+		if !strings.Contains(out.String(), "2024-06-01T12:00:00Z") || !strings.Contains(out.String(), "pending") {
+			mt.Errorf("Expected applied and pending rows, got:\n%s", out.String())
+		}
+	})
+
+	mt.Run("dry run up", func(mt *mtest.T) {
+		var ran []string
+		m := NewMigrator(mt.DB, WithMigrations(testMigrations(&ran)...))
+		mt.AddMockResponses(ledgerResponse(1))
+
+		var out bytes.Buffer
+		if err := m.Run(context.Background(), []string{"-dry-run", "up"}, &out); err != nil {
+			mt.Fatalf("Run returned error: %v", err)
+		}
+		if out.String() != "would apply 2 add status\n" {
+			mt.Errorf("Unexpected output %q", out.String())
+		}
+	})
+
+	mt.Run("unknown command", func(mt *mtest.T) {
+		m := NewMigrator(mt.DB, WithMigrations())
+		if err := m.Run(context.Background(), []string{"sideways"}, &bytes.Buffer{}); err == nil {
+			mt.Error("Expected an error for an unknown command")
+		}
+	})
+}
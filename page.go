@@ -0,0 +1,247 @@
+package basemodel
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultPageSize is used when a page request does not set a limit
+const DefaultPageSize int64 = 20
+
+// Page is a single page of results
+// Next and Prev are opaque continuation tokens; they are empty when there is
+// no page in that direction
+type Page[T any] struct {
+	Items []T    `json:"items"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Total *int64 `json:"total,omitempty"`
+}
+
+// PageKey selects the keyset used to order and continue pages
+type PageKey int
+
+const (
+	// KeysetCreatedAt orders pages by (created_at, _id)
+	KeysetCreatedAt PageKey = iota
+	// KeysetID orders pages by _id alone
+	KeysetID
+)
+
+// PageRequest describes a keyset page
+// Set After to the Next token of a page to move forward, or Before to the
+// Prev token to move backward; leave both empty for the first page
+type PageRequest struct {
+	Limit        int64
+	After        string
+	Before       string
+	Key          PageKey
+	Descending   bool
+	IncludeTotal bool
+}
+
+// OffsetRequest describes an offset page, numbered from 1
+// Offset pagination is meant for admin screens; prefer keyset pages for large collections
+type OffsetRequest struct {
+	Page    int64
+	PerPage int64
+	Sort    bson.D
+}
+
+// pageToken is the decoded content of a continuation token
+type pageToken struct {
+	CreatedAt time.Time   `bson:"c,omitempty"`
+	ID        interface{} `bson:"i"`
+}
+
+func encodePageToken(tok pageToken) (string, error) {
+	data, err := bson.Marshal(tok)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodePageToken(s string) (pageToken, error) {
+	var tok pageToken
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return tok, ErrInvalidPageToken
+	}
+	if err := bson.Unmarshal(data, &tok); err != nil {
+		return tok, ErrInvalidPageToken
+	}
+	return tok, nil
+}
+
+// pageSource is the part of a repository the page helpers query
+type pageSource[PT any] interface {
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]PT, error)
+	Count(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+}
+
+// FindPage returns a keyset page of documents matching the filter
+// Soft deleted documents are excluded according to the repository scope
+func (r *Repository[T, PT]) FindPage(ctx context.Context, filter interface{}, req PageRequest) (*Page[PT], error) {
+	return findPage[T, PT](ctx, r, filter, req)
+}
+
+// FindOffset returns an offset page of documents matching the filter,
+// always including the total count
+// Next and Prev hold the neighbouring page numbers
+func (r *Repository[T, PT]) FindOffset(ctx context.Context, filter interface{}, req OffsetRequest) (*Page[PT], error) {
+	return findOffset[T, PT](ctx, r, filter, req)
+}
+
+// findPage implements FindPage on top of the source's Find and Count
+func findPage[T any, PT document[T]](ctx context.Context, src pageSource[PT], filter interface{}, req PageRequest) (*Page[PT], error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	forward := req.Before == ""
+	token := req.After
+	if !forward {
+		token = req.Before
+	}
+
+	// Walking backward queries in the opposite order and reverses the result
+	dir := 1
+	if req.Descending {
+		dir = -1
+	}
+	if !forward {
+		dir = -dir
+	}
+
+	sort := bson.D{{Key: "_id", Value: dir}}
+	if req.Key == KeysetCreatedAt {
+		sort = bson.D{{Key: "created_at", Value: dir}, {Key: "_id", Value: dir}}
+	}
+
+	query := filter
+	if query == nil {
+		query = bson.D{}
+	}
+	if token != "" {
+		tok, err := decodePageToken(token)
+		if err != nil {
+			return nil, err
+		}
+		query = bson.D{{Key: "$and", Value: bson.A{query, keysetCondition(req.Key, dir, tok)}}}
+	}
+
+	opts := options.Find().SetSort(sort).SetLimit(limit + 1)
+	items, err := src.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := int64(len(items)) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	if !forward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &Page[PT]{Items: items}
+	if len(items) > 0 {
+		first, last := items[0], items[len(items)-1]
+		if hasMore || !forward {
+			if page.Next, err = encodePageToken(tokenFor[T](last, req.Key)); err != nil {
+				return nil, err
+			}
+		}
+		if (forward && req.After != "") || (!forward && hasMore) {
+			if page.Prev, err = encodePageToken(tokenFor[T](first, req.Key)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if req.IncludeTotal {
+		total, err := src.Count(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+// findOffset implements FindOffset on top of the source's Find and Count
+func findOffset[T any, PT document[T]](ctx context.Context, src pageSource[PT], filter interface{}, req OffsetRequest) (*Page[PT], error) {
+	perPage := req.PerPage
+	if perPage <= 0 {
+		perPage = DefaultPageSize
+	}
+	pageNum := req.Page
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	sort := req.Sort
+	if sort == nil {
+		sort = bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}
+	}
+
+	opts := options.Find().SetSort(sort).SetSkip((pageNum - 1) * perPage).SetLimit(perPage)
+	items, err := src.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := src.Count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page[PT]{Items: items, Total: &total}
+	if pageNum*perPage < total {
+		page.Next = strconv.FormatInt(pageNum+1, 10)
+	}
+	if pageNum > 1 {
+		page.Prev = strconv.FormatInt(pageNum-1, 10)
+	}
+
+	return page, nil
+}
+
+// tokenFor builds the continuation token for a model
+func tokenFor[T any, PT document[T]](model PT, key PageKey) pageToken {
+	tok := pageToken{ID: model.documentID()}
+	if key == KeysetCreatedAt {
+		tok.CreatedAt = model.GetCreatedAt()
+	}
+	return tok
+}
+
+// keysetCondition matches documents strictly after the token in the given direction
+func keysetCondition(key PageKey, dir int, tok pageToken) bson.D {
+	op := "$gt"
+	if dir < 0 {
+		op = "$lt"
+	}
+
+	if key == KeysetID {
+		return bson.D{{Key: "_id", Value: bson.D{{Key: op, Value: tok.ID}}}}
+	}
+
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "created_at", Value: bson.D{{Key: op, Value: tok.CreatedAt}}}},
+		bson.D{
+			{Key: "created_at", Value: tok.CreatedAt},
+			{Key: "_id", Value: bson.D{{Key: op, Value: tok.ID}}},
+		},
+	}}}
+}
@@ -0,0 +1,173 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// pagedUsers builds n user documents with increasing created_at
+func pagedUsers(n int) []bson.D {
+	start := time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC)
+	docs := make([]bson.D, n)
+	for i := range docs {
+		docs[i] = bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "created_at", Value: primitive.NewDateTimeFromTime(start.Add(time.Duration(i) * time.Minute))},
+			{Key: "name", Value: string(rune('a' + i))},
+		}
+	}
+	return docs
+}
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	tok := pageToken{
+		CreatedAt: time.Date(2024, 5, 30, 9, 0, 0, 0, time.UTC),
+		ID:        primitive.NewObjectID(),
+	}
+
+	encoded, err := encodePageToken(tok)
+	if err != nil {
+		t.Fatalf("encodePageToken returned error: %v", err)
+	}
+	decoded, err := decodePageToken(encoded)
+	if err != nil {
+		t.Fatalf("decodePageToken returned error: %v", err)
+	}
+	if decoded.ID != tok.ID || !decoded.CreatedAt.Equal(tok.CreatedAt) {
+		t.Errorf("Expected %+v, got %+v", tok, decoded)
+	}
+
+	if _, err := decodePageToken("not a token!"); !errors.Is(err, ErrInvalidPageToken) {
+		t.Errorf("Expected ErrInvalidPageToken, got %v", err)
+	}
+}
+
+func TestRepositoryFindPage(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("first page", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, pagedUsers(3)...))
+
+		page, err := repo.FindPage(context.Background(), bson.M{}, PageRequest{Limit: 2})
+		if err != nil {
+			mt.Fatalf("FindPage returned error: %v", err)
+		}
+		if len(page.Items) != 2 {
+			mt.Fatalf("Expected 2 items, got %d", len(page.Items))
+		}
+		if page.Next == "" {
+			mt.Error("Expected a next token when more items exist")
+		}
+		if page.Prev != "" {
+			mt.Error("Expected no prev token on the first page")
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		if cmd.Lookup("limit").AsInt64() != 3 {
+			mt.Errorf("Expected limit+1 to be requested, got %s", cmd.Lookup("limit"))
+		}
+		sort := cmd.Lookup("sort").Document()
+		if keys, _ := sort.Elements(); len(keys) != 2 || keys[0].Key() != "created_at" || keys[1].Key() != "_id" {
+			mt.Errorf("Expected sort on (created_at, _id), got %s", sort)
+		}
+		if _, ok := scopePredicate(cmd.Lookup("filter").Document()); !ok {
+			mt.Error("Expected soft deleted documents to be excluded")
+		}
+	})
+
+	mt.Run("next page continues after the token", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		docs := pagedUsers(3)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, docs[2]))
+
+		after, _ := encodePageToken(pageToken{ID: docs[1][0].Value.(primitive.ObjectID)})
+		page, err := repo.FindPage(context.Background(), nil, PageRequest{Limit: 2, After: after, Key: KeysetID})
+		if err != nil {
+			mt.Fatalf("FindPage returned error: %v", err)
+		}
+		if len(page.Items) != 1 || page.Next != "" || page.Prev == "" {
+			mt.Errorf("Expected last page with only a prev token, got %+v", page)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		keyset := filter.Lookup("$and").Array().Index(0).Value().Document().Lookup("$and").Array().Index(1).Value().Document()
+		if _, err := keyset.LookupErr("_id", "$gt"); err != nil {
+			mt.Errorf("Expected _id $gt keyset condition, got %s", filter)
+		}
+	})
+
+	mt.Run("previous page is returned in order", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		docs := pagedUsers(3)
+		// Walking backward the server returns items in reverse order
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, docs[1], docs[0]))
+
+		before, _ := encodePageToken(pageToken{ID: docs[2][0].Value.(primitive.ObjectID)})
+		page, err := repo.FindPage(context.Background(), nil, PageRequest{Limit: 2, Before: before, Key: KeysetID})
+		if err != nil {
+			mt.Fatalf("FindPage returned error: %v", err)
+		}
+		if len(page.Items) != 2 || page.Items[0].Name != "a" || page.Items[1].Name != "b" {
+			mt.Errorf("Expected items a, b in order, got %+v", page.Items)
+		}
+		if page.Next == "" || page.Prev != "" {
+			mt.Errorf("Expected only a next token on the first page, got next=%q prev=%q", page.Next, page.Prev)
+		}
+
+		sort := mt.GetStartedEvent().Command.Lookup("sort").Document()
+		if sort.Lookup("_id").AsInt64() != -1 {
+			mt.Errorf("Expected reversed sort when walking backward, got %s", sort)
+		}
+	})
+
+	mt.Run("include total", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, pagedUsers(1)...),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+		)
+
+		page, err := repo.FindPage(context.Background(), nil, PageRequest{IncludeTotal: true})
+		if err != nil {
+			mt.Fatalf("FindPage returned error: %v", err)
+		}
+		if page.Total == nil || *page.Total != 1 {
+			mt.Errorf("Expected total 1, got %v", page.Total)
+		}
+	})
+}
+
+func TestRepositoryFindOffset(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("second page", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, pagedUsers(2)...),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{{Key: "n", Value: 7}}),
+		)
+
+		page, err := repo.FindOffset(context.Background(), nil, OffsetRequest{Page: 2, PerPage: 2})
+		if err != nil {
+			mt.Fatalf("FindOffset returned error: %v", err)
+		}
+		if page.Total == nil || *page.Total != 7 {
+			mt.Errorf("Expected total 7, got %v", page.Total)
+		}
+		if page.Next != "3" || page.Prev != "1" {
+			mt.Errorf("Expected next 3 and prev 1, got next=%q prev=%q", page.Next, page.Prev)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		if cmd.Lookup("skip").AsInt64() != 2 || cmd.Lookup("limit").AsInt64() != 2 {
+			mt.Errorf("Expected skip 2 limit 2, got %s", cmd)
+		}
+	})
+}
@@ -0,0 +1,635 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// document is the constraint for Repository type parameters.
+// PT must be a pointer to a struct that embeds BaseCollection or Base; the
+// unexported identified methods can only be obtained through that embedding.
+type document[T any] interface {
+	*T
+	Model
+	identified
+}
+
+// Repository provides CRUD operations for a model that embeds BaseCollection
+// It applies the insert, update and delete metadata automatically and
+// excludes soft deleted documents from every query unless told otherwise
+type Repository[T any, PT document[T]] struct {
+	collection *mongo.Collection
+	history    *mongo.Collection
+	scope      softDeleteScope
+}
+
+// RepositoryOption configures optional repository behavior
+type RepositoryOption func(*repositoryConfig)
+
+// repositoryConfig holds the settings applied by RepositoryOption
+type repositoryConfig struct {
+	history bool
+}
+
+// WithHistory records every insert, update, soft delete and restore made
+// through the repository in a "<collection>_history" collection
+func WithHistory() RepositoryOption {
+	return func(c *repositoryConfig) {
+		c.history = true
+	}
+}
+
+// NewRepository creates a new repository backed by the given collection
+//
+//	users := basemodel.NewRepository[User](db.Collection("users"))
+func NewRepository[T any, PT document[T]](collection *mongo.Collection, opts ...RepositoryOption) *Repository[T, PT] {
+	var cfg repositoryConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	r := &Repository[T, PT]{
+		collection: collection,
+	}
+	if cfg.history {
+		r.history = collection.Database().Collection(historyCollectionName(collection.Name()))
+	}
+
+	return r
+}
+
+// Collection returns the underlying MongoDB collection
+func (r *Repository[T, PT]) Collection() *mongo.Collection {
+	return r.collection
+}
+
+// WithDeleted returns a copy of the repository whose queries include soft deleted documents
+func (r *Repository[T, PT]) WithDeleted() *Repository[T, PT] {
+	return r.withScope(scopeWithDeleted)
+}
+
+// OnlyDeleted returns a copy of the repository whose queries match only soft deleted documents
+func (r *Repository[T, PT]) OnlyDeleted() *Repository[T, PT] {
+	return r.withScope(scopeOnlyDeleted)
+}
+
+// withScope returns a copy of the repository using the given soft delete scope
+func (r *Repository[T, PT]) withScope(scope softDeleteScope) *Repository[T, PT] {
+	scoped := *r
+	scoped.scope = scope
+	return &scoped
+}
+
+// Create sets the insert metadata, validates and inserts the model
+// It returns a DuplicateKeyError when the model breaks a unique index
+func (r *Repository[T, PT]) Create(ctx context.Context, model PT) error {
+	if err := stampTenant(ctx, model); err != nil {
+		return err
+	}
+	if err := beforeInsert(ctx, model); err != nil {
+		return err
+	}
+
+	setInsertMeta(ctx, model)
+	if err := requireID(model); err != nil {
+		return err
+	}
+	if err := Validate(model); err != nil {
+		return err
+	}
+
+	if _, err := r.collection.InsertOne(ctx, model); err != nil {
+		return translateWriteError(err)
+	}
+
+	if r.history != nil {
+		after, err := bson.Marshal(model)
+		if err != nil {
+			return err
+		}
+		if err := r.recordHistory(ctx, HistoryInsert, model.documentID(), nil, after); err != nil {
+			return err
+		}
+	}
+
+	return afterInsert(ctx, model)
+}
+
+// FindByID finds a document by the string form of its ID, such as an ObjectID hex
+func (r *Repository[T, PT]) FindByID(ctx context.Context, id string) (PT, error) {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindOne(ctx, bson.M{"_id": objID})
+}
+
+// FindOne returns the first document matching the filter
+func (r *Repository[T, PT]) FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (PT, error) {
+	query, err := r.scopedFilter(ctx, r.scope, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	model := PT(new(T))
+	err = r.collection.FindOne(ctx, query, opts...).Decode(model)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := afterFind(ctx, model); err != nil {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// Find returns all documents matching the filter
+func (r *Repository[T, PT]) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]PT, error) {
+	query, err := r.scopedFilter(ctx, r.scope, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.collection.Find(ctx, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var models []PT
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	for _, model := range models {
+		if err := afterFind(ctx, model); err != nil {
+			return nil, err
+		}
+	}
+
+	return models, nil
+}
+
+// FindAll returns all documents in the repository scope
+func (r *Repository[T, PT]) FindAll(ctx context.Context) ([]PT, error) {
+	return r.Find(ctx, bson.M{})
+}
+
+// FindDeleted returns all soft deleted documents
+func (r *Repository[T, PT]) FindDeleted(ctx context.Context) ([]PT, error) {
+	return r.OnlyDeleted().Find(ctx, bson.M{})
+}
+
+// Count returns the number of documents matching the filter
+func (r *Repository[T, PT]) Count(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error) {
+	query, err := r.scopedFilter(ctx, r.scope, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.collection.CountDocuments(ctx, query, opts...)
+}
+
+// Aggregate runs the pipeline and decodes every result into results,
+// which must be a pointer to a slice
+// The soft delete predicate, the tenant for tenant models and the expiry
+// check for expiring models are prepended as a $match stage
+func (r *Repository[T, PT]) Aggregate(ctx context.Context, pipeline mongo.Pipeline, results interface{}, opts ...*options.AggregateOptions) error {
+	conds, err := modelConditions(ctx, PT(new(T)))
+	if err != nil {
+		return err
+	}
+	if match := append(r.scope.predicate(), conds...); len(match) > 0 {
+		pipeline = append(mongo.Pipeline{{{Key: "$match", Value: match}}}, pipeline...)
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline, opts...)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, results)
+}
+
+// Update sets the update metadata, validates and saves the model
+// Only the fields produced by BuildUpdate are written, so _id and created_at are never overwritten
+// It returns ErrNotFound when the document does not exist in the repository scope
+//
+// For models embedding VersionFields the update only applies when the stored
+// version matches the model's version; the version is incremented atomically
+// and ErrVersionConflict is returned when someone else updated it first
+func (r *Repository[T, PT]) Update(ctx context.Context, model PT) error {
+	if err := beforeUpdate(ctx, model); err != nil {
+		return err
+	}
+
+	setUpdateMeta(ctx, model)
+	if err := Validate(model); err != nil {
+		return err
+	}
+
+	update, err := updateDocument(model, nil)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": model.documentID()}
+	v, isVersioned := any(model).(versioned)
+	if isVersioned {
+		filter["version"] = v.GetVersion()
+		update = append(update, bson.E{Key: "$inc", Value: bson.M{"version": 1}})
+	}
+
+	before, err := r.snapshot(ctx, model.documentID())
+	if err != nil {
+		return err
+	}
+
+	query, err := r.scopedFilter(ctx, r.scope, filter)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(ctx, query, update)
+	if err != nil {
+		return translateWriteError(err)
+	}
+	if result.MatchedCount > 0 {
+		if isVersioned {
+			v.setVersion(v.GetVersion() + 1)
+		}
+		if err := r.recordHistory(ctx, HistoryUpdate, model.documentID(), before, nil); err != nil {
+			return err
+		}
+		return afterUpdate(ctx, model)
+	}
+
+	if isVersioned {
+		exists, err := r.Count(ctx, bson.M{"_id": model.documentID()})
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrVersionConflict
+		}
+	}
+
+	return ErrNotFound
+}
+
+// UpdateMany applies the update to every document matching the filter
+// and returns the number of modified documents
+// For tenant models it returns ErrTenantUpdate when the update writes tenant_id
+// updated_at, updated_by for auditable models and the version for versioned
+// models are maintained as in Update
+// With history enabled the matched documents are loaded first and an update
+// entry is recorded for each of them
+// It returns a DuplicateKeyError when an updated document breaks a unique index
+func (r *Repository[T, PT]) UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (int64, error) {
+	if err := checkTenantUpdate(PT(new(T)), update); err != nil {
+		return 0, err
+	}
+	update, err := updateManyDocument(ctx, PT(new(T)), update)
+	if err != nil {
+		return 0, err
+	}
+	query, err := r.scopedFilter(ctx, r.scope, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	if r.history != nil {
+		return r.updateManyWithHistory(ctx, query, update, opts)
+	}
+
+	result, err := r.collection.UpdateMany(ctx, query, update, opts...)
+	if err != nil {
+		return 0, translateWriteError(err)
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// Upsert updates the active document matching the filter with the model, or
+// inserts the model when none matches, and returns the stored document and
+// whether it was inserted
+// _id, created_at, created_by and tenant_id are only written on insert; the
+// payload and updated_at are always $set. A nil filter matches the model's _id
+//
+// The stored document and whether it was inserted are returned by the same
+// atomic write
+//
+//	user, inserted, err := users.Upsert(ctx, bson.M{"email": u.Email}, u)
+//
+// BeforeUpdate runs when the filter matches a document and BeforeInsert
+// otherwise; AfterInsert or AfterUpdate and the history entry follow the
+// actual outcome. Unlike Update there is no version check
+//
+// The before hook is chosen by reading the matched document before the
+// write, so a concurrent insert or delete of a matching document can make
+// BeforeInsert run for what turns out to be an update, or the reverse
+func (r *Repository[T, PT]) Upsert(ctx context.Context, filter interface{}, model PT) (PT, bool, error) {
+	before, err := r.beforeUpsert(ctx, filter, model)
+	if err != nil {
+		return nil, false, err
+	}
+
+	match, update, err := prepareUpsert(ctx, model, filter)
+	if err != nil {
+		return nil, false, err
+	}
+	query, err := r.scopedFilter(ctx, scopeActive, match)
+	if err != nil {
+		return nil, false, err
+	}
+
+	raw, inserted, err := r.findAndModify(ctx, query, update)
+	if err != nil {
+		return nil, false, err
+	}
+
+	stored := PT(new(T))
+	if err := bson.Unmarshal(raw, stored); err != nil {
+		return nil, false, err
+	}
+	if err := afterFind(ctx, stored); err != nil {
+		return nil, false, err
+	}
+	if err := r.afterUpsert(ctx, model, before, raw, inserted); err != nil {
+		return stored, inserted, err
+	}
+
+	return stored, inserted, nil
+}
+
+// findAndModify upserts with the findAndModify command, returning the
+// stored document and whether it was inserted, as reported by
+// lastErrorObject.updatedExisting
+// The command runs on the database with the session of ctx; the driver
+// exposes no other way to read updatedExisting
+func (r *Repository[T, PT]) findAndModify(ctx context.Context, query interface{}, update bson.D) (bson.Raw, bool, error) {
+	cmd := bson.D{
+		{Key: "findAndModify", Value: r.collection.Name()},
+		{Key: "query", Value: query},
+		{Key: "update", Value: update},
+		{Key: "new", Value: true},
+		{Key: "upsert", Value: true},
+	}
+
+	var reply struct {
+		Value           bson.Raw `bson:"value"`
+		LastErrorObject struct {
+			UpdatedExisting bool `bson:"updatedExisting"`
+		} `bson:"lastErrorObject"`
+	}
+	if err := r.collection.Database().RunCommand(ctx, cmd).Decode(&reply); err != nil {
+		return nil, false, translateWriteError(err)
+	}
+
+	return reply.Value, !reply.LastErrorObject.UpdatedExisting, nil
+}
+
+// SoftDelete marks the document as deleted by setting its deleted_at timestamp
+// It returns ErrNotFound when the document does not exist or is already deleted
+//
+// When the model implements BeforeSoftDeleter or AfterSoftDeleter the
+// document is loaded first so the hooks can inspect it
+func (r *Repository[T, PT]) SoftDelete(ctx context.Context, id string) error {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return err
+	}
+
+	model := PT(new(T))
+	if hasSoftDeleteHooks(model) {
+		if model, err = r.withScope(scopeActive).FindOne(ctx, bson.M{"_id": objID}); err != nil {
+			return err
+		}
+		if err := beforeSoftDelete(ctx, model); err != nil {
+			return err
+		}
+	}
+
+	setDeleteMeta(ctx, model)
+
+	set := bson.M{"deleted_at": model.GetDeletedAt()}
+	if actor, ok := ActorFromContext(ctx); ok && r.isAuditable() {
+		set["deleted_by"] = actor
+	}
+
+	filter := bson.M{"_id": objID}
+	update := bson.M{"$set": set}
+	if r.isVersioned() {
+		update["$inc"] = bson.M{"version": 1}
+	}
+
+	before, err := r.snapshot(ctx, objID)
+	if err != nil {
+		return err
+	}
+
+	query, err := r.scopedFilter(ctx, scopeActive, filter)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(ctx, query, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	if err := r.recordHistory(ctx, HistorySoftDelete, objID, before, nil); err != nil {
+		return err
+	}
+
+	return afterSoftDelete(ctx, model)
+}
+
+// Restore undoes a soft delete by unsetting deleted_at and setting updated_at
+// It returns ErrNotDeleted when the document exists but was never deleted, and
+// a DuplicateKeyError when an active document already holds a unique value
+func (r *Repository[T, PT]) Restore(ctx context.Context, id string) error {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return err
+	}
+
+	var meta BaseCollection
+	meta.ClearDeleteMeta()
+
+	set := bson.M{"updated_at": meta.UpdatedAt}
+	unset := bson.M{"deleted_at": ""}
+	if r.isAuditable() {
+		unset["deleted_by"] = ""
+		if actor, ok := ActorFromContext(ctx); ok {
+			set["updated_by"] = actor
+		}
+	}
+
+	filter := bson.M{"_id": objID}
+	update := bson.M{
+		"$set":   set,
+		"$unset": unset,
+	}
+	if r.isVersioned() {
+		update["$inc"] = bson.M{"version": 1}
+	}
+
+	before, err := r.snapshot(ctx, objID)
+	if err != nil {
+		return err
+	}
+
+	query, err := r.scopedFilter(ctx, scopeOnlyDeleted, filter)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.collection.UpdateOne(ctx, query, update)
+	if err != nil {
+		return translateWriteError(err)
+	}
+	if result.MatchedCount > 0 {
+		return r.recordHistory(ctx, HistoryRestore, objID, before, nil)
+	}
+
+	active, err := r.withScope(scopeActive).Count(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if active > 0 {
+		return ErrNotDeleted
+	}
+
+	return ErrNotFound
+}
+
+// HardDelete permanently removes the document regardless of its soft delete state
+// It returns the number of removed documents, or ErrNotFound when nothing was removed
+func (r *Repository[T, PT]) HardDelete(ctx context.Context, id string) (int64, error) {
+	objID, err := PT(new(T)).parseID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	query, err := destructiveFilter(ctx, PT(new(T)), scopeWithDeleted, bson.M{"_id": objID})
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.collection.DeleteOne(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	if result.DeletedCount == 0 {
+		return 0, ErrNotFound
+	}
+
+	return result.DeletedCount, nil
+}
+
+// Purge permanently removes soft deleted documents whose deleted_at is older
+// than the retention window and returns the number of removed documents
+func (r *Repository[T, PT]) Purge(ctx context.Context, olderThan time.Duration) (int64, error) {
+	cutoff := timeNow().Add(-olderThan)
+	filter := bson.M{"deleted_at": bson.M{"$lt": cutoff}}
+
+	query, err := destructiveFilter(ctx, PT(new(T)), scopeWithDeleted, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := r.collection.DeleteMany(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// current reads the raw document matching the query, or nil when none does
+func (r *Repository[T, PT]) current(ctx context.Context, query interface{}) (bson.Raw, error) {
+	raw, err := r.collection.FindOne(ctx, query).Raw()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return raw, err
+}
+
+// beforeUpsert runs BeforeUpdate when the upsert filter matches an active
+// document and BeforeInsert otherwise, and returns the matched document
+// The document is only looked up when the model has a before hook or
+// history is enabled; a nil filter matches the model's _id
+func (r *Repository[T, PT]) beforeUpsert(ctx context.Context, filter interface{}, model PT) (bson.Raw, error) {
+	var before bson.Raw
+	if hasUpsertHooks(model) || r.history != nil {
+		if match := upsertMatch(filter, model); match != nil {
+			query, err := r.scopedFilter(ctx, scopeActive, match)
+			if err != nil {
+				return nil, err
+			}
+			if before, err = r.current(ctx, query); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if before != nil {
+		return before, beforeUpdate(ctx, model)
+	}
+	return nil, beforeInsert(ctx, model)
+}
+
+// afterUpsert records the history of an upsert and runs AfterInsert or
+// AfterUpdate; the after snapshot is read from the collection when nil
+func (r *Repository[T, PT]) afterUpsert(ctx context.Context, model PT, before, after bson.Raw, inserted bool) error {
+	// The stored _id may come from the filter rather than the model
+	var id interface{} = model.documentID()
+	switch {
+	case after != nil:
+		id = after.Lookup("_id")
+	case before != nil && !inserted:
+		id = before.Lookup("_id")
+	}
+
+	if inserted {
+		if err := r.recordHistory(ctx, HistoryInsert, id, nil, after); err != nil {
+			return err
+		}
+		return afterInsert(ctx, model)
+	}
+
+	if err := r.recordHistory(ctx, HistoryUpdate, id, before, after); err != nil {
+		return err
+	}
+	return afterUpdate(ctx, model)
+}
+
+// scopedFilter combines the filter with the given soft delete scope and the
+// tenant and expiry conditions of the model
+func (r *Repository[T, PT]) scopedFilter(ctx context.Context, scope softDeleteScope, filter interface{}) (interface{}, error) {
+	return scopedFilter(ctx, PT(new(T)), scope, filter)
+}
+
+// isVersioned reports whether the repository model embeds VersionFields
+func (r *Repository[T, PT]) isVersioned() bool {
+	_, ok := any(PT(new(T))).(versioned)
+	return ok
+}
+
+// isAuditable reports whether the repository model embeds AuditFields
+func (r *Repository[T, PT]) isAuditable() bool {
+	_, ok := any(PT(new(T))).(auditable)
+	return ok
+}
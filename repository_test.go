@@ -0,0 +1,644 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func newMockT(t *testing.T) *mtest.T {
+	return mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+}
+
+// userDoc builds a raw user document as it would be returned by the server
+func userDoc(id primitive.ObjectID, name string) bson.D {
+	return bson.D{
+		{Key: "_id", Value: id},
+		{Key: "created_at", Value: primitive.NewDateTimeFromTime(time.Now())},
+		{Key: "name", Value: name},
+		{Key: "email", Value: name + "@example.com"},
+	}
+}
+
+// scopePredicate returns the soft delete predicate appended to a sent filter
+func scopePredicate(filter bson.Raw) (bson.Raw, bool) {
+	and, err := filter.LookupErr("$and")
+	if err != nil {
+		return nil, false
+	}
+	values, err := and.Array().Values()
+	if err != nil || len(values) == 0 {
+		return nil, false
+	}
+	return values[len(values)-1].Document(), true
+}
+
+func TestRepositoryCreate(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("sets insert metadata", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		user := &TestUser{Name: "John Doe", Email: "john@example.com"}
+		if err := repo.Create(context.Background(), user); err != nil {
+			mt.Fatalf("Create returned error: %v", err)
+		}
+
+		if user.Oid.IsZero() {
+			mt.Error("Expected Oid to be set by Create")
+		}
+		if user.CreatedAt.IsZero() {
+			mt.Error("Expected CreatedAt to be set by Create")
+		}
+
+		docs := mt.GetStartedEvent().Command.Lookup("documents").Array()
+		sent := docs.Index(0).Value().Document()
+		if sent.Lookup("_id").ObjectID() != user.Oid {
+			mt.Error("Expected inserted _id to match the model Oid")
+		}
+	})
+
+	mt.Run("duplicate key", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Code:    11000,
+			Message: `E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "john@example.com" }`,
+		}))
+
+		err := repo.Create(context.Background(), &TestUser{Name: "John Doe", Email: "john@example.com"})
+
+		var dup *DuplicateKeyError
+		if !errors.As(err, &dup) {
+			mt.Fatalf("Expected DuplicateKeyError, got %v", err)
+		}
+		if dup.Field != "email" || dup.Index != "email_1" {
+			mt.Errorf("Expected field email on index email_1, got %q on %q", dup.Field, dup.Index)
+		}
+		if !errors.Is(err, ErrDuplicateKey) {
+			mt.Error("Expected errors.Is to match ErrDuplicateKey")
+		}
+	})
+}
+
+func TestRepositoryFindByID(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("found", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(id, "john")))
+
+		user, err := repo.FindByID(context.Background(), id.Hex())
+		if err != nil {
+			mt.Fatalf("FindByID returned error: %v", err)
+		}
+		if user.Oid != id || user.Name != "john" {
+			mt.Errorf("Unexpected user decoded: %+v", user)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		pred, ok := scopePredicate(filter)
+		if !ok || pred.Lookup("deleted_at").Type != bson.TypeNull {
+			mt.Errorf("Expected FindByID filter to exclude soft deleted documents, got %s", filter)
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+		_, err := repo.FindByID(context.Background(), primitive.NewObjectID().Hex())
+		if !errors.Is(err, ErrNotFound) {
+			mt.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	mt.Run("invalid id", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+
+		_, err := repo.FindByID(context.Background(), "not-an-id")
+		if !errors.Is(err, ErrInvalidID) {
+			mt.Errorf("Expected ErrInvalidID, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryFindAll(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("decodes all documents", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch,
+			userDoc(primitive.NewObjectID(), "alice"),
+			userDoc(primitive.NewObjectID(), "bob"),
+		))
+
+		users, err := repo.FindAll(context.Background())
+		if err != nil {
+			mt.Fatalf("FindAll returned error: %v", err)
+		}
+		if len(users) != 2 {
+			mt.Fatalf("Expected 2 users, got %d", len(users))
+		}
+		if users[0].Name != "alice" || users[1].Name != "bob" {
+			mt.Errorf("Unexpected users decoded: %s, %s", users[0].Name, users[1].Name)
+		}
+	})
+}
+
+func TestRepositoryUpdate(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("sets update metadata", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		user := &TestUser{Name: "John Doe"}
+		user.SetInsertMeta()
+		if err := repo.Update(context.Background(), user); err != nil {
+			mt.Fatalf("Update returned error: %v", err)
+		}
+		if user.UpdatedAt == nil {
+			mt.Error("Expected UpdatedAt to be set by Update")
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		user := &TestUser{Name: "John Doe"}
+		user.SetInsertMeta()
+		err := repo.Update(context.Background(), user)
+		if !errors.Is(err, ErrNotFound) {
+			mt.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryUpdateMany(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("sets update metadata", func(mt *mtest.T) {
+		clock := useFakeClock(mt.T)
+		repo := NewRepository[TestInvoice](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		ctx := WithActor(context.Background(), "alice")
+		if _, err := repo.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"amount": 0}}); err != nil {
+			mt.Fatalf("UpdateMany returned error: %v", err)
+		}
+
+		sent := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if !sent.Lookup("$set", "updated_at").Time().Equal(clock.Now()) {
+			mt.Errorf("Expected updated_at to be set, got %s", sent)
+		}
+		if sent.Lookup("$set", "updated_by").StringValue() != "alice" {
+			mt.Errorf("Expected updated_by to be set, got %s", sent)
+		}
+		if _, err := sent.LookupErr("$set", "amount"); err != nil {
+			mt.Errorf("Expected the caller's $set to be kept, got %s", sent)
+		}
+	})
+
+	mt.Run("duplicate key", func(mt *mtest.T) {
+		dupResponse := mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Code:    11000,
+			Message: `E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "team@example.com" }`,
+		})
+		for _, opts := range [][]RepositoryOption{nil, {WithHistory()}} {
+			repo := NewRepository[TestUser](mt.Coll, opts...)
+			if len(opts) > 0 {
+				mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(primitive.NewObjectID(), "john")), dupResponse)
+			} else {
+				mt.AddMockResponses(dupResponse)
+			}
+
+			_, err := repo.UpdateMany(context.Background(), bson.M{}, bson.M{"$set": bson.M{"email": "team@example.com"}})
+
+			var dup *DuplicateKeyError
+			if !errors.As(err, &dup) || dup.Field != "email" {
+				mt.Errorf("Expected DuplicateKeyError on email (history %v), got %v", len(opts) > 0, err)
+			}
+		}
+	})
+
+	mt.Run("pipeline", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		pipeline := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "name", Value: bson.D{{Key: "$toUpper", Value: "$name"}}}}}}}
+		if _, err := repo.UpdateMany(context.Background(), bson.M{}, pipeline); err != nil {
+			mt.Fatalf("UpdateMany returned error: %v", err)
+		}
+
+		stages, _ := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Array().Values()
+		if len(stages) != 2 {
+			mt.Fatalf("Expected a trailing metadata stage, got %v", stages)
+		}
+		if _, err := stages[1].Document().LookupErr("$set", "updated_at"); err != nil {
+			mt.Errorf("Expected updated_at in the trailing stage, got %s", stages[1])
+		}
+	})
+}
+
+func TestMemoryRepositoryUpdateMany(t *testing.T) {
+	clock := useFakeClock(t)
+	repo := NewMemoryRepository[TestInvoice]()
+
+	invoice := &TestInvoice{Amount: 100}
+	if err := repo.Create(context.Background(), invoice); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	ctx := WithActor(context.Background(), "alice")
+	if _, err := repo.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"amount": 0}}); err != nil {
+		t.Fatalf("UpdateMany returned error: %v", err)
+	}
+
+	updated, err := repo.FindByID(ctx, invoice.GetID())
+	if err != nil {
+		t.Fatalf("FindByID returned error: %v", err)
+	}
+	if updated.UpdatedAt == nil || !updated.UpdatedAt.Equal(clock.Now()) {
+		t.Errorf("Expected updated_at %v, got %v", clock.Now(), updated.UpdatedAt)
+	}
+	if updated.UpdatedBy != "alice" {
+		t.Errorf("Expected updated_by alice, got %q", updated.UpdatedBy)
+	}
+}
+
+// findAndModifyResponse returns the reply of an upsert that stored doc
+func findAndModifyResponse(doc bson.D, updatedExisting bool) bson.D {
+	return mtest.CreateSuccessResponse(
+		bson.E{Key: "value", Value: doc},
+		bson.E{Key: "lastErrorObject", Value: bson.D{{Key: "n", Value: 1}, {Key: "updatedExisting", Value: updatedExisting}}},
+	)
+}
+
+func TestRepositoryUpsert(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("returns the written document when the filter field changes", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		existing := primitive.NewObjectID()
+		mt.AddMockResponses(findAndModifyResponse(userDoc(existing, "new"), true))
+
+		user := &TestUser{Name: "new", Email: "new@example.com"}
+		stored, inserted, err := repo.Upsert(context.Background(), bson.M{"email": "old@example.com"}, user)
+		if err != nil {
+			mt.Fatalf("Upsert returned error: %v", err)
+		}
+		if inserted {
+			mt.Error("Expected the existing document to be reported as updated")
+		}
+		if stored == nil || stored.Oid != existing || stored.Email != "new@example.com" {
+			mt.Errorf("Expected the updated document to be returned, got %+v", stored)
+		}
+
+		started := mt.GetStartedEvent()
+		if started.CommandName != "findAndModify" || !started.Command.Lookup("new").Boolean() || !started.Command.Lookup("upsert").Boolean() {
+			mt.Fatalf("Expected an upserting findAndModify returning the new document, got %s", started.Command)
+		}
+		update := started.Command.Lookup("update").Document()
+		if _, err := update.LookupErr("$setOnInsert", "created_at"); err != nil {
+			mt.Error("Expected created_at in $setOnInsert")
+		}
+		if _, err := update.LookupErr("$setOnInsert", "_id"); err != nil {
+			mt.Error("Expected _id in $setOnInsert for a custom filter")
+		}
+		if _, err := update.LookupErr("$set", "created_at"); err == nil {
+			mt.Error("Expected created_at not to be $set")
+		}
+		if _, err := update.LookupErr("$set", "updated_at"); err != nil {
+			mt.Error("Expected updated_at to be $set")
+		}
+		if _, err := update.LookupErr("$unset", "deleted_at"); err != nil {
+			mt.Error("Expected deleted_at seeded by the scope to be $unset")
+		}
+		if mt.GetStartedEvent() != nil {
+			mt.Error("Expected no read after the write")
+		}
+	})
+
+	mt.Run("reads updatedExisting when matching by _id", func(mt *mtest.T) {
+		for _, updatedExisting := range []bool{false, true} {
+			repo := NewRepository[TestUser](mt.Coll)
+			user := &TestUser{Name: "John Doe"}
+			user.SetInsertMeta()
+			mt.AddMockResponses(findAndModifyResponse(userDoc(user.Oid, "john"), updatedExisting))
+
+			_, inserted, err := repo.Upsert(context.Background(), nil, user)
+			if err != nil {
+				mt.Fatalf("Upsert returned error: %v", err)
+			}
+			if inserted == updatedExisting {
+				mt.Errorf("Expected inserted to be %v when updatedExisting is %v", !updatedExisting, updatedExisting)
+			}
+
+			cmd := mt.GetStartedEvent().Command
+			if _, err := cmd.LookupErr("update", "$setOnInsert", "_id"); err == nil {
+				mt.Error("Expected _id not to be in $setOnInsert when matching by _id")
+			}
+		}
+	})
+
+	mt.Run("follows the write when a concurrent insert wins", func(mt *mtest.T) {
+		repo := NewRepository[TestMember](mt.Coll)
+		member := &TestMember{Email: "john@example.com"}
+		member.Oid = primitive.NewObjectID()
+		doc := bson.D{{Key: "_id", Value: member.Oid}, {Key: "email", Value: "john@example.com"}}
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch),
+			findAndModifyResponse(doc, true),
+		)
+
+		_, inserted, err := repo.Upsert(context.Background(), nil, member)
+		if err != nil {
+			mt.Fatalf("Upsert returned error: %v", err)
+		}
+		if inserted {
+			mt.Error("Expected the outcome reported by the server")
+		}
+		if got := strings.Join(member.Calls, ","); got != "BeforeInsert,AfterUpdate" {
+			mt.Errorf("Expected the before hook of the read and the after hook of the write, got %s", got)
+		}
+	})
+
+	mt.Run("inserts a new model without reading", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		user := &TestUser{Name: "John Doe"}
+		mt.AddMockResponses(findAndModifyResponse(bson.D{{Key: "name", Value: "John Doe"}}, false))
+
+		_, inserted, err := repo.Upsert(context.Background(), nil, user)
+		if err != nil {
+			mt.Fatalf("Upsert returned error: %v", err)
+		}
+		if !inserted {
+			mt.Error("Expected a model without an ID to be inserted")
+		}
+		if name := mt.GetStartedEvent().CommandName; name != "findAndModify" {
+			mt.Errorf("Expected a single findAndModify, got %s", name)
+		}
+	})
+
+	mt.Run("runs the hooks and history of the outcome", func(mt *mtest.T) {
+		for _, exists := range []bool{true, false} {
+			repo := NewRepository[TestMember](mt.Coll, WithHistory())
+			member := &TestMember{Email: "john@example.com"}
+			member.Oid = primitive.NewObjectID()
+			doc := bson.D{{Key: "_id", Value: member.Oid}, {Key: "email", Value: "john@example.com"}}
+
+			current := mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch)
+			if exists {
+				current = mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch, doc)
+			}
+			mt.AddMockResponses(current, findAndModifyResponse(doc, exists), mtest.CreateSuccessResponse())
+
+			if _, _, err := repo.Upsert(context.Background(), nil, member); err != nil {
+				mt.Fatalf("Upsert returned error: %v", err)
+			}
+
+			expected, operation := "BeforeInsert,AfterInsert", HistoryInsert
+			if exists {
+				expected, operation = "BeforeUpdate,AfterUpdate", HistoryUpdate
+			}
+			if got := strings.Join(member.Calls, ","); got != expected {
+				mt.Errorf("Expected hooks %s, got %s", expected, got)
+			}
+
+			var entry bson.Raw
+			for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+				if e.CommandName == "insert" {
+					entry = e.Command.Lookup("documents").Array().Index(0).Value().Document()
+				}
+			}
+			if entry == nil || entry.Lookup("operation").StringValue() != string(operation) {
+				mt.Errorf("Expected a %s history entry, got %s", operation, entry)
+			}
+		}
+	})
+}
+
+func TestRepositorySoftDelete(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("sets deleted_at", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		if err := repo.SoftDelete(context.Background(), primitive.NewObjectID().Hex()); err != nil {
+			mt.Fatalf("SoftDelete returned error: %v", err)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if _, err := update.LookupErr("$set", "deleted_at"); err != nil {
+			mt.Error("Expected SoftDelete to $set deleted_at")
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}))
+
+		err := repo.SoftDelete(context.Background(), primitive.NewObjectID().Hex())
+		if !errors.Is(err, ErrNotFound) {
+			mt.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryRestore(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("unsets deleted_at", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		if err := repo.Restore(context.Background(), primitive.NewObjectID().Hex()); err != nil {
+			mt.Fatalf("Restore returned error: %v", err)
+		}
+
+		update := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if _, err := update.LookupErr("$unset", "deleted_at"); err != nil {
+			mt.Error("Expected Restore to $unset deleted_at")
+		}
+		if _, err := update.LookupErr("$set", "updated_at"); err != nil {
+			mt.Error("Expected Restore to $set updated_at")
+		}
+	})
+
+	mt.Run("not deleted", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+		)
+
+		err := repo.Restore(context.Background(), primitive.NewObjectID().Hex())
+		if !errors.Is(err, ErrNotDeleted) {
+			mt.Errorf("Expected ErrNotDeleted, got %v", err)
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch),
+		)
+
+		err := repo.Restore(context.Background(), primitive.NewObjectID().Hex())
+		if !errors.Is(err, ErrNotFound) {
+			mt.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryHardDelete(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("removes document", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+
+		count, err := repo.HardDelete(context.Background(), primitive.NewObjectID().Hex())
+		if err != nil {
+			mt.Fatalf("HardDelete returned error: %v", err)
+		}
+		if count != 1 {
+			mt.Errorf("Expected 1 removed document, got %d", count)
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}))
+
+		_, err := repo.HardDelete(context.Background(), primitive.NewObjectID().Hex())
+		if !errors.Is(err, ErrNotFound) {
+			mt.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryPurge(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("removes documents deleted before the cutoff", func(mt *mtest.T) {
+		clock := useFakeClock(mt.T)
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 5}))
+
+		count, err := repo.Purge(context.Background(), 30*24*time.Hour)
+		if err != nil {
+			mt.Fatalf("Purge returned error: %v", err)
+		}
+		if count != 5 {
+			mt.Errorf("Expected 5 removed documents, got %d", count)
+		}
+
+		query := mt.GetStartedEvent().Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q").Document()
+		cutoff, ok := query.Lookup("deleted_at", "$lt").DateTimeOK()
+		if !ok {
+			mt.Fatalf("Expected deleted_at $lt cutoff, got %s", query)
+		}
+		expected := clock.Now().Add(-30 * 24 * time.Hour)
+		if !time.UnixMilli(cutoff).Equal(expected) {
+			mt.Errorf("Expected cutoff %v, got %v", expected, time.UnixMilli(cutoff))
+		}
+	})
+}
+
+func TestRepositoryScopes(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("find excludes deleted by default", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+		if _, err := repo.Find(context.Background(), bson.M{"name": "john"}); err != nil {
+			mt.Fatalf("Find returned error: %v", err)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		pred, ok := scopePredicate(filter)
+		if !ok || pred.Lookup("deleted_at").Type != bson.TypeNull {
+			mt.Errorf("Expected deleted_at: null predicate, got %s", filter)
+		}
+	})
+
+	mt.Run("with deleted leaves filter untouched", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, bson.D{{Key: "n", Value: 3}}))
+
+		count, err := repo.WithDeleted().Count(context.Background(), bson.M{"name": "john"})
+		if err != nil {
+			mt.Fatalf("Count returned error: %v", err)
+		}
+		if count != 3 {
+			mt.Errorf("Expected count 3, got %d", count)
+		}
+
+		cmd := mt.GetStartedEvent().Command
+		match := cmd.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+		if _, ok := scopePredicate(match); ok {
+			mt.Errorf("Expected no soft delete predicate, got %s", match)
+		}
+	})
+
+	mt.Run("only deleted", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+		if _, err := repo.OnlyDeleted().FindAll(context.Background()); err != nil {
+			mt.Fatalf("FindAll returned error: %v", err)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		pred, ok := scopePredicate(filter)
+		if !ok {
+			mt.Fatalf("Expected soft delete predicate, got %s", filter)
+		}
+		if _, err := pred.LookupErr("deleted_at", "$ne"); err != nil {
+			mt.Errorf("Expected deleted_at: {$ne: null} predicate, got %s", pred)
+		}
+	})
+
+	mt.Run("scopes do not leak into the parent repository", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		_ = repo.WithDeleted()
+
+		if repo.scope != scopeActive {
+			mt.Error("Expected WithDeleted to return a copy")
+		}
+	})
+
+	mt.Run("aggregate prepends match stage", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+		var results []bson.M
+		pipeline := mongo.Pipeline{{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$name"}}}}}
+		if err := repo.Aggregate(context.Background(), pipeline, &results); err != nil {
+			mt.Fatalf("Aggregate returned error: %v", err)
+		}
+
+		stages, _ := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Values()
+		if len(stages) != 2 {
+			mt.Fatalf("Expected 2 stages, got %d", len(stages))
+		}
+		if _, err := stages[0].Document().LookupErr("$match", "deleted_at"); err != nil {
+			mt.Errorf("Expected first stage to match active documents, got %s", stages[0])
+		}
+	})
+}
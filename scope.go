@@ -0,0 +1,93 @@
+package basemodel
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// softDeleteScope controls how repository queries treat soft deleted documents
+type softDeleteScope int
+
+const (
+	// scopeActive matches only documents that are not soft deleted (default)
+	scopeActive softDeleteScope = iota
+	// scopeWithDeleted matches every document regardless of deleted_at
+	scopeWithDeleted
+	// scopeOnlyDeleted matches only soft deleted documents
+	scopeOnlyDeleted
+)
+
+// predicate returns the deleted_at condition for the scope, or nil when
+// no condition is needed
+// Like IsDeleted, a missing or null deleted_at means the document is active
+func (s softDeleteScope) predicate() bson.D {
+	switch s {
+	case scopeWithDeleted:
+		return nil
+	case scopeOnlyDeleted:
+		return bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$ne", Value: nil}}}}
+	default:
+		return bson.D{{Key: "deleted_at", Value: nil}}
+	}
+}
+
+// apply combines the caller's filter with the scope predicate
+func (s softDeleteScope) apply(filter interface{}) interface{} {
+	pred := s.predicate()
+	if pred == nil {
+		if filter == nil {
+			return bson.D{}
+		}
+		return filter
+	}
+	if filter == nil {
+		return pred
+	}
+
+	return bson.D{{Key: "$and", Value: bson.A{filter, pred}}}
+}
+
+// modelConditions returns the conditions a model type adds to every query:
+// the tenant from the context for tenant models and the expiry check for
+// expiring models. It returns ErrNoTenant when a tenant model is used with a
+// context that carries no tenant
+func modelConditions(ctx context.Context, m Model) (bson.D, error) {
+	tenant, err := tenantCondition(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(tenant, expiryCondition(m)...), nil
+}
+
+// scopedFilter combines a filter with the soft delete scope and the model conditions
+func scopedFilter(ctx context.Context, m Model, scope softDeleteScope, filter interface{}) (interface{}, error) {
+	conds, err := modelConditions(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return withConditions(scope.apply(filter), conds), nil
+}
+
+// destructiveFilter combines a filter with the soft delete scope and the
+// tenant condition but not the expiry check, so hard deletes and purges also
+// reach expired documents the TTL monitor has not removed yet
+func destructiveFilter(ctx context.Context, m Model, scope softDeleteScope, filter interface{}) (interface{}, error) {
+	tenant, err := tenantCondition(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return withConditions(scope.apply(filter), tenant), nil
+}
+
+// withConditions appends the model conditions to a scoped filter
+func withConditions(scoped interface{}, conds bson.D) interface{} {
+	if len(conds) == 0 {
+		return scoped
+	}
+
+	return bson.D{{Key: "$and", Value: bson.A{scoped, conds}}}
+}
@@ -0,0 +1,35 @@
+package basemodel
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store is the set of repository operations implemented by both Repository
+// and MemoryRepository
+// Depend on it in services so unit tests can run against the in-memory
+// implementation and integration tests against MongoDB
+//
+//	type UserService struct {
+//		users basemodel.Store[User, *User]
+//	}
+type Store[T any, PT document[T]] interface {
+	Create(ctx context.Context, model PT) error
+	FindByID(ctx context.Context, id string) (PT, error)
+	FindOne(ctx context.Context, filter interface{}, opts ...*options.FindOneOptions) (PT, error)
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]PT, error)
+	FindAll(ctx context.Context) ([]PT, error)
+	FindDeleted(ctx context.Context) ([]PT, error)
+	FindPage(ctx context.Context, filter interface{}, req PageRequest) (*Page[PT], error)
+	FindOffset(ctx context.Context, filter interface{}, req OffsetRequest) (*Page[PT], error)
+	Count(ctx context.Context, filter interface{}, opts ...*options.CountOptions) (int64, error)
+	Update(ctx context.Context, model PT) error
+	Upsert(ctx context.Context, filter interface{}, model PT) (PT, bool, error)
+	UpdateMany(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (int64, error)
+	SoftDelete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string) error
+	HardDelete(ctx context.Context, id string) (int64, error)
+	Purge(ctx context.Context, olderThan time.Duration) (int64, error)
+}
@@ -0,0 +1,172 @@
+package basemodel
+
+import (
+	"context"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// tenantKey is the context key for the current tenant
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx carrying the tenant the operation belongs to
+// HTTP middleware typically sets it from the authenticated account
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant stored in ctx by WithTenant
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && tenant != ""
+}
+
+// TenantFields holds the tenant owning the document
+// Embed it inline next to BaseCollection or Base, together with any other
+// *Fields mixin. Repositories of such models stamp the tenant from the
+// context on insert, restrict every filter, update and aggregate to that
+// tenant, and return ErrNoTenant when the context carries no tenant
+type TenantFields struct {
+	TenantID string `json:"tenant_id" bson:"tenant_id"`
+}
+
+// GetTenantID returns the tenant owning the document
+func (t *TenantFields) GetTenantID() string {
+	return t.TenantID
+}
+
+func (t *TenantFields) setTenantID(tenantID string) {
+	t.TenantID = tenantID
+}
+
+// TenantCollection extends BaseCollection with the tenant owning the document
+// It is a shorthand for embedding BaseCollection and TenantFields
+type TenantCollection struct {
+	BaseCollection `bson:",inline"`
+	TenantFields   `bson:",inline"`
+}
+
+// tenantScoped is implemented by models embedding TenantFields
+type tenantScoped interface {
+	GetTenantID() string
+	setTenantID(tenantID string)
+}
+
+// stampTenant sets the tenant from the context on tenant models
+func stampTenant(ctx context.Context, m Model) error {
+	t, ok := m.(tenantScoped)
+	if !ok {
+		return nil
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return ErrNoTenant
+	}
+	t.setTenantID(tenant)
+
+	return nil
+}
+
+// tenantCondition returns the tenant_id condition for tenant models, nil for
+// other models, and ErrNoTenant when the context carries no tenant
+func tenantCondition(ctx context.Context, m Model) (bson.D, error) {
+	if _, ok := m.(tenantScoped); !ok {
+		return nil, nil
+	}
+
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+
+	return bson.D{{Key: "tenant_id", Value: tenant}}, nil
+}
+
+// checkTenantUpdate returns ErrTenantUpdate when an update of a tenant model
+// writes tenant_id with any operator, or with a pipeline stage that sets,
+// unsets or replaces fields
+func checkTenantUpdate(m Model, update interface{}) error {
+	if _, ok := m.(tenantScoped); !ok {
+		return nil
+	}
+
+	// Wrapping lets pipelines, which are arrays, be normalized too
+	doc, err := normalizeDocument(bson.D{{Key: "u", Value: update}})
+	if err != nil {
+		return err
+	}
+
+	var touches bool
+	switch u := doc[0].Value.(type) {
+	case bson.D:
+		touches = updateTouchesTenant(u)
+	case bson.A:
+		touches = pipelineTouchesTenant(u)
+	}
+	if touches {
+		return ErrTenantUpdate
+	}
+
+	return nil
+}
+
+// updateTouchesTenant reports whether an update document writes tenant_id
+func updateTouchesTenant(update bson.D) bool {
+	for _, op := range update {
+		if !strings.HasPrefix(op.Key, "$") {
+			if isTenantPath(op.Key) {
+				return true
+			}
+			continue
+		}
+		fields, _ := op.Value.(bson.D)
+		for _, f := range fields {
+			if isTenantPath(f.Key) {
+				return true
+			}
+			if target, ok := f.Value.(string); ok && op.Key == "$rename" && isTenantPath(target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pipelineTouchesTenant reports whether an update pipeline writes tenant_id
+// Stages that reshape the whole document are rejected
+func pipelineTouchesTenant(pipeline bson.A) bool {
+	for _, s := range pipeline {
+		stage, _ := s.(bson.D)
+		for _, e := range stage {
+			switch e.Key {
+			case "$set", "$addFields":
+				fields, _ := e.Value.(bson.D)
+				for _, f := range fields {
+					if isTenantPath(f.Key) {
+						return true
+					}
+				}
+			case "$unset":
+				names, ok := e.Value.(bson.A)
+				if !ok {
+					names = bson.A{e.Value}
+				}
+				for _, name := range names {
+					if field, ok := name.(string); ok && isTenantPath(field) {
+						return true
+					}
+				}
+			default:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isTenantPath reports whether a field path is tenant_id or inside it
+func isTenantPath(path string) bool {
+	return path == "tenant_id" || strings.HasPrefix(path, "tenant_id.")
+}
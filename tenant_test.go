@@ -0,0 +1,221 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestProject is a test struct that embeds TenantCollection
+type TestProject struct {
+	TenantCollection `bson:",inline"`
+	Name             string `json:"name" bson:"name"`
+}
+
+// tenantOf returns the tenant_id condition of a sent filter
+func tenantOf(filter bson.Raw) (string, bool) {
+	values, err := filter.Lookup("$and").Array().Values()
+	if err != nil || len(values) != 2 {
+		return "", false
+	}
+	return values[1].Document().Lookup("tenant_id").StringValueOK()
+}
+
+func TestTenantFromContext(t *testing.T) {
+	if _, ok := TenantFromContext(context.Background()); ok {
+		t.Error("Expected no tenant in an empty context")
+	}
+
+	tenant, ok := TenantFromContext(WithTenant(context.Background(), "acme"))
+	if !ok || tenant != "acme" {
+		t.Errorf("Expected tenant acme, got %q", tenant)
+	}
+}
+
+func TestTenantRepository(t *testing.T) {
+	mt := newMockT(t)
+	ctx := WithTenant(context.Background(), "acme")
+
+	mt.Run("refuses operations without a tenant", func(mt *mtest.T) {
+		repo := NewRepository[TestProject](mt.Coll)
+		bare := context.Background()
+
+		if err := repo.Create(bare, &TestProject{Name: "Apollo"}); !errors.Is(err, ErrNoTenant) {
+			mt.Errorf("Create: expected ErrNoTenant, got %v", err)
+		}
+		if _, err := repo.Find(bare, bson.M{}); !errors.Is(err, ErrNoTenant) {
+			mt.Errorf("Find: expected ErrNoTenant, got %v", err)
+		}
+		if _, err := repo.Count(bare, bson.M{}); !errors.Is(err, ErrNoTenant) {
+			mt.Errorf("Count: expected ErrNoTenant, got %v", err)
+		}
+		if err := repo.SoftDelete(bare, primitive.NewObjectID().Hex()); !errors.Is(err, ErrNoTenant) {
+			mt.Errorf("SoftDelete: expected ErrNoTenant, got %v", err)
+		}
+		if _, err := repo.HardDelete(bare, primitive.NewObjectID().Hex()); !errors.Is(err, ErrNoTenant) {
+			mt.Errorf("HardDelete: expected ErrNoTenant, got %v", err)
+		}
+		var results []bson.M
+		if err := repo.Aggregate(bare, mongo.Pipeline{}, &results); !errors.Is(err, ErrNoTenant) {
+			mt.Errorf("Aggregate: expected ErrNoTenant, got %v", err)
+		}
+
+		if e := mt.GetStartedEvent(); e != nil {
+			mt.Errorf("Expected no command to be sent, got %s", e.CommandName)
+		}
+	})
+
+	mt.Run("stamps the tenant on insert", func(mt *mtest.T) {
+		repo := NewRepository[TestProject](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		project := &TestProject{Name: "Apollo"}
+		project.TenantID = "other"
+		if err := repo.Create(ctx, project); err != nil {
+			mt.Fatalf("Create returned error: %v", err)
+		}
+		if project.GetTenantID() != "acme" {
+			mt.Errorf("Expected tenant acme, got %q", project.GetTenantID())
+		}
+
+		sent := mt.GetStartedEvent().Command.Lookup("documents").Array().Index(0).Value().Document()
+		if sent.Lookup("tenant_id").StringValue() != "acme" {
+			mt.Error("Expected tenant_id acme in the inserted document")
+		}
+	})
+
+	mt.Run("filters reads by tenant", func(mt *mtest.T) {
+		repo := NewRepository[TestProject](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.projects", mtest.FirstBatch))
+
+		if _, err := repo.Find(ctx, bson.M{"name": "Apollo"}); err != nil {
+			mt.Fatalf("Find returned error: %v", err)
+		}
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		if tenant, ok := tenantOf(filter); !ok || tenant != "acme" {
+			mt.Errorf("Expected the filter to be restricted to tenant acme, got %s", filter)
+		}
+	})
+
+	mt.Run("never updates the tenant", func(mt *mtest.T) {
+		repo := NewRepository[TestProject](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		project := &TestProject{Name: "Apollo"}
+		project.SetInsertMeta()
+		project.TenantID = "other"
+		if err := repo.Update(ctx, project); err != nil {
+			mt.Fatalf("Update returned error: %v", err)
+		}
+
+		stmt := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		if _, err := stmt.LookupErr("u", "$set", "tenant_id"); err == nil {
+			mt.Error("Expected tenant_id not to be $set")
+		}
+		if tenant, ok := tenantOf(stmt.Lookup("q").Document()); !ok || tenant != "acme" {
+			mt.Error("Expected the update filter to be restricted to tenant acme")
+		}
+	})
+
+	mt.Run("update many cannot move documents to another tenant", func(mt *mtest.T) {
+		repo := NewRepository[TestProject](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		rejected := []interface{}{
+			bson.M{"$set": bson.M{"tenant_id": "other"}},
+			bson.M{"$unset": bson.M{"tenant_id": ""}},
+			bson.M{"$rename": bson.M{"owner": "tenant_id"}},
+			bson.M{"$setOnInsert": bson.M{"tenant_id.name": "other"}},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{"tenant_id": "other"}}}},
+			mongo.Pipeline{{{Key: "$replaceWith", Value: bson.M{"name": "x"}}}},
+		}
+		for _, update := range rejected {
+			if _, err := repo.UpdateMany(ctx, bson.M{}, update); !errors.Is(err, ErrTenantUpdate) {
+				mt.Errorf("Expected ErrTenantUpdate for %v, got %v", update, err)
+			}
+		}
+		if mt.GetStartedEvent() != nil {
+			mt.Error("Expected rejected updates not to be sent")
+		}
+
+		if _, err := repo.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"name": "Apollo"}}); err != nil {
+			mt.Errorf("Expected other fields to be updatable, got %v", err)
+		}
+	})
+
+	mt.Run("matches the tenant first in aggregates", func(mt *mtest.T) {
+		repo := NewRepository[TestProject](mt.Coll)
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.projects", mtest.FirstBatch))
+
+		var results []bson.M
+		if err := repo.Aggregate(ctx, mongo.Pipeline{}, &results); err != nil {
+			mt.Fatalf("Aggregate returned error: %v", err)
+		}
+
+		stages, _ := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Values()
+		match := stages[0].Document().Lookup("$match").Document()
+		if match.Lookup("tenant_id").StringValue() != "acme" {
+			mt.Errorf("Expected $match on tenant acme, got %s", match)
+		}
+		if _, err := match.LookupErr("deleted_at"); err != nil {
+			mt.Error("Expected $match to keep the soft delete predicate")
+		}
+	})
+}
+
+// TestContract combines every field mixin with a UUID key
+type TestContract struct {
+	Base[UUID]    `bson:",inline"`
+	TenantFields  `bson:",inline"`
+	VersionFields `bson:",inline"`
+	AuditFields   `bson:",inline"`
+	ExpiryFields  `bson:",inline"`
+	Title         string `json:"title" bson:"title"`
+}
+
+func TestTenantFieldMixins(t *testing.T) {
+	clock := useFakeClock(t)
+	repo := NewMemoryRepository[TestContract]()
+	acme := WithActor(WithTenant(context.Background(), "acme"), "alice")
+
+	contract := &TestContract{Title: "Lease"}
+	contract.SetExpiry(time.Hour)
+	if err := repo.Create(acme, contract); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	if contract.GetTenantID() != "acme" || contract.GetVersion() != 1 || contract.GetCreatedBy() != "alice" {
+		t.Errorf("Expected tenant, version and actor to be stamped, got %+v", contract)
+	}
+
+	globex := WithTenant(context.Background(), "globex")
+	if _, err := repo.FindByID(globex, contract.GetID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another tenant not to see the contract, got %v", err)
+	}
+
+	stale, err := repo.FindByID(acme, contract.GetID())
+	if err != nil {
+		t.Fatalf("FindByID returned error: %v", err)
+	}
+	contract.Title = "Lease v2"
+	if err := repo.Update(WithActor(acme, "bob"), contract); err != nil {
+		t.Fatalf("Update returned error: %v", err)
+	}
+	if contract.GetVersion() != 2 || contract.GetUpdatedBy() != "bob" {
+		t.Errorf("Expected version 2 updated by bob, got %d by %q", contract.GetVersion(), contract.GetUpdatedBy())
+	}
+	if err := repo.Update(acme, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for a stale copy, got %v", err)
+	}
+
+	clock.Advance(2 * time.Hour)
+	if _, err := repo.FindByID(acme, contract.GetID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the expired contract to be hidden, got %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WithTransaction runs fn inside a transaction and commits it
+// fn receives a context bound to the session; pass it to every repository or
+// collection call that must be part of the transaction
+// The whole function is retried on TransientTransactionError and the commit
+// on UnknownTransactionCommitResult, following the driver's convenient
+// transaction API, so fn must be safe to run more than once
+// Transactions require a replica set or sharded cluster
+func WithTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error, opts ...*options.TransactionOptions) error {
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	}, opts...)
+
+	return err
+}
+
+// unitOperation is the kind of change a unit of work applies to a model
+type unitOperation int
+
+const (
+	unitNew unitOperation = iota
+	unitDirty
+	unitDeleted
+)
+
+// UnitTarget is where a unit of work writes a registered model
+// A *Repository is a target whose history, when enabled with WithHistory,
+// is recorded in the same transaction; wrap a plain collection with
+// UnitCollection
+type UnitTarget interface {
+	unitCollections() (collection, history *mongo.Collection)
+}
+
+// collectionTarget is a unit of work target without history
+type collectionTarget struct {
+	collection *mongo.Collection
+}
+
+// UnitCollection returns a unit of work target writing to the collection
+// without recording history
+func UnitCollection(collection *mongo.Collection) UnitTarget {
+	return collectionTarget{collection: collection}
+}
+
+func (t collectionTarget) unitCollections() (*mongo.Collection, *mongo.Collection) {
+	return t.collection, nil
+}
+
+func (r *Repository[T, PT]) unitCollections() (*mongo.Collection, *mongo.Collection) {
+	return r.collection, r.history
+}
+
+// unitEntry is a model registered with a unit of work
+type unitEntry struct {
+	collection *mongo.Collection
+	history    *mongo.Collection
+	model      Model
+	op         unitOperation
+}
+
+// newUnitEntry registers the model with the collections of the target
+func newUnitEntry(target UnitTarget, model Model, op unitOperation) unitEntry {
+	collection, history := target.unitCollections()
+	return unitEntry{collection: collection, history: history, model: model, op: op}
+}
+
+// UnitOfWork collects new, dirty and deleted models and writes them in a
+// single transaction on Commit
+// Metadata is applied like the repository does: insert metadata for new
+// models, update metadata for dirty ones and delete metadata for deleted
+// ones. Versioned models get the same optimistic concurrency check and
+// tenant models are stamped and filtered with the tenant from the context
+// Before hooks run inside the transaction, again when it is retried, and
+// after hooks once it committed. Models registered with a repository that
+// has history enabled get their history entries written in the same
+// transaction
+type UnitOfWork struct {
+	client  *mongo.Client
+	entries []unitEntry
+}
+
+// NewUnitOfWork creates an empty unit of work for the client
+//
+//	uow := basemodel.NewUnitOfWork(client)
+//	uow.RegisterNew(orders, order)
+//	uow.RegisterDirty(basemodel.UnitCollection(db.Collection("products")), product)
+//	err := uow.Commit(ctx)
+func NewUnitOfWork(client *mongo.Client) *UnitOfWork {
+	return &UnitOfWork{client: client}
+}
+
+// RegisterNew schedules the model to be inserted
+func (u *UnitOfWork) RegisterNew(target UnitTarget, model Model) {
+	u.entries = append(u.entries, newUnitEntry(target, model, unitNew))
+}
+
+// RegisterDirty schedules the model to be updated
+func (u *UnitOfWork) RegisterDirty(target UnitTarget, model Model) {
+	u.entries = append(u.entries, newUnitEntry(target, model, unitDirty))
+}
+
+// RegisterDeleted schedules the model to be soft deleted
+func (u *UnitOfWork) RegisterDeleted(target UnitTarget, model Model) {
+	u.entries = append(u.entries, newUnitEntry(target, model, unitDeleted))
+}
+
+// Len returns the number of registered models
+func (u *UnitOfWork) Len() int {
+	return len(u.entries)
+}
+
+// Clear drops every registered model without writing anything
+func (u *UnitOfWork) Clear() {
+	u.entries = nil
+}
+
+// Commit writes every registered model in registration order inside one
+// transaction and clears the unit of work when it succeeds
+// Any failure aborts the transaction, leaving the database untouched; dirty
+// or deleted models that no longer match return ErrNotFound or, for
+// versioned models, ErrVersionConflict
+// Errors from after hooks are joined and returned, but the commit stands
+func (u *UnitOfWork) Commit(ctx context.Context) error {
+	if len(u.entries) == 0 {
+		return nil
+	}
+
+	err := WithTransaction(ctx, u.client, func(ctx context.Context) error {
+		for _, entry := range u.entries {
+			if err := entry.apply(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Versions only move once the transaction is committed, so a retried
+	// transaction keeps comparing against the loaded version
+	for _, entry := range u.entries {
+		if v, ok := entry.model.(versioned); ok && entry.op != unitNew {
+			v.setVersion(v.GetVersion() + 1)
+		}
+	}
+	entries := u.entries
+	u.entries = nil
+
+	var errs []error
+	for _, entry := range entries {
+		if err := entry.after(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// apply writes a single entry within the transaction
+func (e unitEntry) apply(ctx context.Context) error {
+	switch e.op {
+	case unitNew:
+		if err := stampTenant(ctx, e.model); err != nil {
+			return err
+		}
+		if err := beforeInsert(ctx, e.model); err != nil {
+			return err
+		}
+		setInsertMeta(ctx, e.model)
+		if err := requireID(e.model); err != nil {
+			return err
+		}
+		if err := Validate(e.model); err != nil {
+			return err
+		}
+		if _, err := e.collection.InsertOne(ctx, e.model); err != nil {
+			return translateWriteError(err)
+		}
+		m, ok := e.model.(identified)
+		if e.history == nil || !ok {
+			return nil
+		}
+		after, err := bson.Marshal(e.model)
+		if err != nil {
+			return err
+		}
+		return recordHistory(ctx, e.collection, e.history, HistoryInsert, m.documentID(), nil, after)
+	case unitDirty:
+		if err := beforeUpdate(ctx, e.model); err != nil {
+			return err
+		}
+		setUpdateMeta(ctx, e.model)
+		if err := Validate(e.model); err != nil {
+			return err
+		}
+		update, err := updateDocument(e.model, nil)
+		if err != nil {
+			return err
+		}
+		return e.updateOne(ctx, HistoryUpdate, update)
+	default:
+		if err := beforeSoftDelete(ctx, e.model); err != nil {
+			return err
+		}
+		setDeleteMeta(ctx, e.model)
+		set := bson.D{{Key: "deleted_at", Value: e.model.GetDeletedAt()}}
+		if actor, ok := ActorFromContext(ctx); ok {
+			if _, isAuditable := e.model.(auditable); isAuditable {
+				set = append(set, bson.E{Key: "deleted_by", Value: actor})
+			}
+		}
+		return e.updateOne(ctx, HistorySoftDelete, bson.D{{Key: "$set", Value: set}})
+	}
+}
+
+// after runs the after hook of a committed entry
+func (e unitEntry) after(ctx context.Context) error {
+	switch e.op {
+	case unitNew:
+		return afterInsert(ctx, e.model)
+	case unitDirty:
+		return afterUpdate(ctx, e.model)
+	default:
+		return afterSoftDelete(ctx, e.model)
+	}
+}
+
+// updateOne updates the active document of the entry, checking the version
+// of versioned models, and records the change as op in the history
+func (e unitEntry) updateOne(ctx context.Context, op HistoryOperation, update bson.D) error {
+	m, ok := e.model.(identified)
+	if !ok || !m.hasID() {
+		return ErrInvalidID
+	}
+	id := m.documentID()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	v, isVersioned := e.model.(versioned)
+	if isVersioned {
+		filter = append(filter, bson.E{Key: "version", Value: v.GetVersion()})
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}})
+	}
+
+	query, err := scopedFilter(ctx, e.model, scopeActive, filter)
+	if err != nil {
+		return err
+	}
+
+	before, err := snapshot(ctx, e.collection, e.history, id)
+	if err != nil {
+		return err
+	}
+
+	result, err := e.collection.UpdateOne(ctx, query, update)
+	if err != nil {
+		return translateWriteError(err)
+	}
+	if result.MatchedCount > 0 {
+		return recordHistory(ctx, e.collection, e.history, op, id, before, nil)
+	}
+
+	if isVersioned {
+		query, err := scopedFilter(ctx, e.model, scopeActive, bson.D{{Key: "_id", Value: id}})
+		if err != nil {
+			return err
+		}
+		exists, err := e.collection.CountDocuments(ctx, query)
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return fmt.Errorf("basemodel: %s %s: %w", e.collection.Name(), e.model.GetID(), ErrVersionConflict)
+		}
+	}
+
+	return fmt.Errorf("basemodel: %s %s: %w", e.collection.Name(), e.model.GetID(), ErrNotFound)
+}
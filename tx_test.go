@@ -0,0 +1,270 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestWithTransaction(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("commits", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		err := WithTransaction(context.Background(), mt.Client, func(ctx context.Context) error {
+			return repo.Create(ctx, &TestUser{Name: "John Doe"})
+		})
+		if err != nil {
+			mt.Fatalf("WithTransaction returned error: %v", err)
+		}
+
+		if got := strings.Join(startedCommands(mt), ","); got != "insert "+mt.Coll.Name()+",commitTransaction" {
+			mt.Errorf("Expected insert then commitTransaction, got %s", got)
+		}
+	})
+
+	mt.Run("retries transient errors", func(mt *mtest.T) {
+		repo := NewRepository[TestUser](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateCommandErrorResponse(mtest.CommandError{
+				Code:    112,
+				Name:    "WriteConflict",
+				Message: "write conflict",
+				Labels:  []string{"TransientTransactionError"},
+			}),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		attempts := 0
+		err := WithTransaction(context.Background(), mt.Client, func(ctx context.Context) error {
+			attempts++
+			return repo.Create(ctx, &TestUser{Name: "John Doe"})
+		})
+		if err != nil {
+			mt.Fatalf("WithTransaction returned error: %v", err)
+		}
+		if attempts != 2 {
+			mt.Errorf("Expected 2 attempts, got %d", attempts)
+		}
+	})
+
+	mt.Run("aborts on error", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+		boom := errors.New("out of stock")
+
+		err := WithTransaction(context.Background(), mt.Client, func(ctx context.Context) error {
+			if _, err := mt.Coll.InsertOne(ctx, bson.M{"sku": "A1"}); err != nil {
+				return err
+			}
+			return boom
+		})
+		if !errors.Is(err, boom) {
+			mt.Fatalf("Expected the callback error, got %v", err)
+		}
+
+		if got := strings.Join(startedCommands(mt), ","); got != "insert "+mt.Coll.Name()+",abortTransaction" {
+			mt.Errorf("Expected insert then abortTransaction, got %s", got)
+		}
+	})
+}
+
+func TestUnitOfWork(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("commits new, dirty and deleted models", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		order := &TestUser{Name: "order"}
+		account := &TestAccount{Balance: 100}
+		account.SetInsertMeta()
+		stale := &TestUser{Name: "stale"}
+		stale.SetInsertMeta()
+
+		uow := NewUnitOfWork(mt.Client)
+		uow.RegisterNew(NewRepository[TestUser](mt.Coll), order)
+		uow.RegisterDirty(UnitCollection(mt.Coll), account)
+		uow.RegisterDeleted(UnitCollection(mt.Coll), stale)
+
+		if err := uow.Commit(context.Background()); err != nil {
+			mt.Fatalf("Commit returned error: %v", err)
+		}
+
+		if order.Oid.IsZero() {
+			mt.Error("Expected insert metadata on the new model")
+		}
+		if account.UpdatedAt == nil {
+			mt.Error("Expected update metadata on the dirty model")
+		}
+		if account.GetVersion() != 2 {
+			mt.Errorf("Expected version 2 after commit, got %d", account.GetVersion())
+		}
+		if !stale.IsDeleted() {
+			mt.Error("Expected delete metadata on the deleted model")
+		}
+		if uow.Len() != 0 {
+			mt.Errorf("Expected the unit of work to be cleared, got %d entries", uow.Len())
+		}
+
+		coll := mt.Coll.Name()
+		got := strings.Join(startedCommands(mt), ",")
+		if got != "insert "+coll+",update "+coll+",update "+coll+",commitTransaction" {
+			mt.Errorf("Unexpected commands %s", got)
+		}
+	})
+
+	mt.Run("records repository history in the transaction", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(id, "john")),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch, userDoc(id, "johnny")),
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(),
+		)
+
+		changed := &TestUser{Name: "johnny"}
+		changed.Oid = id
+
+		uow := NewUnitOfWork(mt.Client)
+		users := NewRepository[TestUser](mt.Coll, WithHistory())
+		uow.RegisterNew(users, &TestUser{Name: "jane"})
+		uow.RegisterDirty(users, changed)
+
+		if err := uow.Commit(context.Background()); err != nil {
+			mt.Fatalf("Commit returned error: %v", err)
+		}
+
+		coll, history := mt.Coll.Name(), mt.Coll.Name()+"_history"
+		var got []string
+		var txn []int64
+		for e := mt.GetStartedEvent(); e != nil; e = mt.GetStartedEvent() {
+			name := e.CommandName
+			if c, ok := e.Command.Lookup(name).StringValueOK(); ok {
+				name += " " + c
+			}
+			got = append(got, name)
+			if n, ok := e.Command.Lookup("txnNumber").Int64OK(); ok {
+				txn = append(txn, n)
+			}
+		}
+		expected := "insert " + coll + ",insert " + history + ",find " + coll + ",update " + coll + ",find " + coll + ",insert " + history + ",commitTransaction"
+		if strings.Join(got, ",") != expected {
+			mt.Errorf("Expected %s, got %s", expected, strings.Join(got, ","))
+		}
+		if len(txn) != len(got) {
+			mt.Errorf("Expected every command in the transaction, got txnNumber on %d of %d", len(txn), len(got))
+		}
+		for _, n := range txn {
+			if n != txn[0] {
+				mt.Errorf("Expected a single transaction, got txnNumbers %v", txn)
+				break
+			}
+		}
+	})
+
+	mt.Run("aborts on version conflict", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, "db.accounts", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		account := &TestAccount{Balance: 100}
+		account.SetInsertMeta()
+
+		uow := NewUnitOfWork(mt.Client)
+		uow.RegisterNew(UnitCollection(mt.Coll), &TestUser{Name: "order"})
+		uow.RegisterDirty(UnitCollection(mt.Coll), account)
+
+		if err := uow.Commit(context.Background()); !errors.Is(err, ErrVersionConflict) {
+			mt.Fatalf("Expected ErrVersionConflict, got %v", err)
+		}
+		if account.GetVersion() != 1 {
+			mt.Errorf("Expected version to stay 1, got %d", account.GetVersion())
+		}
+		if uow.Len() != 2 {
+			mt.Errorf("Expected entries to be kept after a failed commit, got %d", uow.Len())
+		}
+		names := startedCommands(mt)
+		if names[len(names)-1] != "abortTransaction" {
+			mt.Errorf("Expected the transaction to be aborted, got %v", names)
+		}
+	})
+
+	mt.Run("runs hooks around the commit", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		added := &TestMember{Email: "John@Example.com"}
+		changed := &TestMember{Email: "jane@example.com"}
+		changed.SetInsertMeta()
+		removed := &TestMember{Email: "old@example.com"}
+		removed.SetInsertMeta()
+
+		uow := NewUnitOfWork(mt.Client)
+		uow.RegisterNew(UnitCollection(mt.Coll), added)
+		uow.RegisterDirty(UnitCollection(mt.Coll), changed)
+		uow.RegisterDeleted(UnitCollection(mt.Coll), removed)
+
+		if err := uow.Commit(context.Background()); err != nil {
+			mt.Fatalf("Commit returned error: %v", err)
+		}
+		if added.Email != "john@example.com" {
+			mt.Error("Expected BeforeInsert to run before the insert")
+		}
+		for _, tc := range []struct {
+			member   *TestMember
+			expected string
+		}{
+			{added, "BeforeInsert,AfterInsert"},
+			{changed, "BeforeUpdate,AfterUpdate"},
+			{removed, "BeforeSoftDelete,AfterSoftDelete"},
+		} {
+			if got := strings.Join(tc.member.Calls, ","); got != tc.expected {
+				mt.Errorf("Expected hooks %s, got %s", tc.expected, got)
+			}
+		}
+	})
+
+	mt.Run("a rejecting before hook aborts the transaction", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		first := &TestMember{Email: "john@example.com"}
+		rejected := &TestMember{Email: "jane@example.com", Reject: "BeforeInsert"}
+
+		uow := NewUnitOfWork(mt.Client)
+		uow.RegisterNew(UnitCollection(mt.Coll), first)
+		uow.RegisterNew(UnitCollection(mt.Coll), rejected)
+
+		if err := uow.Commit(context.Background()); !errors.Is(err, errHookRejected) {
+			mt.Fatalf("Expected errHookRejected, got %v", err)
+		}
+		if got := strings.Join(first.Calls, ","); got != "BeforeInsert" {
+			mt.Errorf("Expected no after hook without a commit, got %s", got)
+		}
+		if got := strings.Join(startedCommands(mt), ","); got != "insert "+mt.Coll.Name()+",abortTransaction" {
+			mt.Errorf("Expected the transaction to be aborted, got %s", got)
+		}
+	})
+}
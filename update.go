@@ -0,0 +1,323 @@
+package basemodel
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// BuildUpdate sets the update metadata on the model and returns an update
+// document that $sets every field of the model and $unsets the omitempty
+// fields left out of it, so clearing a field persists
+// Fields owned by other operations (_id, created_at, deleted_at, tenant_id
+// and their audit/version counterparts) are never included
+func BuildUpdate(model Model) (bson.D, error) {
+	model.SetUpdateMeta()
+	return updateDocument(model, nil)
+}
+
+// BuildUpdateDiff sets the update metadata on the model and returns an update
+// document containing only the fields that differ from snapshot, the
+// previously loaded state of the same document
+// Fields present in snapshot but omitted from the model are $unset
+func BuildUpdateDiff(model, snapshot Model) (bson.D, error) {
+	model.SetUpdateMeta()
+	return updateDocument(model, snapshot)
+}
+
+// updateDocument builds the $set/$unset document for a model whose update
+// metadata has already been applied
+func updateDocument(model, snapshot Model) (bson.D, error) {
+	current, err := bson.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := bson.Raw(current).Elements()
+	if err != nil {
+		return nil, err
+	}
+
+	var previous bson.Raw
+	if snapshot != nil {
+		if previous, err = bson.Marshal(snapshot); err != nil {
+			return nil, err
+		}
+	}
+
+	protected := protectedFields(model)
+	set := bson.D{}
+	seen := make(map[string]bool, len(elems))
+	for _, elem := range elems {
+		key := elem.Key()
+		seen[key] = true
+		if protected[key] {
+			continue
+		}
+
+		value := elem.Value()
+		if previous != nil && key != "updated_at" {
+			old, err := previous.LookupErr(key)
+			if err == nil && old.Type == value.Type && bytes.Equal(old.Value, value.Value) {
+				continue
+			}
+		}
+		set = append(set, bson.E{Key: key, Value: value})
+	}
+
+	update := bson.D{{Key: "$set", Value: set}}
+
+	// Without a snapshot every omitempty field missing from the model may
+	// have been cleared, so it is unset
+	var omitted []string
+	if previous != nil {
+		oldElems, err := previous.Elements()
+		if err != nil {
+			return nil, err
+		}
+		for _, elem := range oldElems {
+			omitted = append(omitted, elem.Key())
+		}
+	} else {
+		omitted = omitEmptyFields(reflect.TypeOf(model))
+	}
+
+	unset := bson.D{}
+	for _, key := range omitted {
+		if !seen[key] && !protected[key] {
+			unset = append(unset, bson.E{Key: key, Value: ""})
+		}
+	}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+
+	return update, nil
+}
+
+// omitEmptyFields returns the top level bson keys of a model type tagged
+// omitempty, including those of inlined structs
+func omitEmptyFields(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := bsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+		if inline {
+			keys = append(keys, omitEmptyFields(field.Type)...)
+			continue
+		}
+
+		for _, opt := range strings.Split(field.Tag.Get("bson"), ",")[1:] {
+			if opt == "omitempty" {
+				keys = append(keys, name)
+			}
+		}
+	}
+
+	return keys
+}
+
+// protectedFields returns the fields an update must never write for the model
+func protectedFields(model Model) map[string]bool {
+	fields := map[string]bool{
+		"_id":        true,
+		"created_at": true,
+		"deleted_at": true,
+	}
+	if _, ok := model.(auditable); ok {
+		fields["created_by"] = true
+		fields["deleted_by"] = true
+	}
+	if _, ok := model.(versioned); ok {
+		fields["version"] = true
+	}
+	if _, ok := model.(tenantScoped); ok {
+		fields["tenant_id"] = true
+	}
+
+	return fields
+}
+
+// upsertDocument builds an upsert update for a model whose insert and update
+// metadata have already been applied: the payload and updated_at are $set,
+// while _id, created_at, created_by and tenant_id are only written when inserting
+// Leave out _id when the upsert filter already matches on it
+func upsertDocument(model Model, includeID bool) (bson.D, error) {
+	update, err := updateDocument(model, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := bson.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+
+	insertOnly := []string{"created_at", "created_by", "tenant_id"}
+	if includeID {
+		insertOnly = append([]string{"_id"}, insertOnly...)
+	}
+	onInsert := bson.D{}
+	for _, key := range insertOnly {
+		if value, err := bson.Raw(raw).LookupErr(key); err == nil {
+			onInsert = append(onInsert, bson.E{Key: key, Value: value})
+		}
+	}
+	update = append(update, bson.E{Key: "$setOnInsert", Value: onInsert})
+
+	// The active scope matches deleted_at: null, which the server copies into
+	// an inserted document; Create leaves the field out, so upserts do too
+	update = setPath(update, "$unset.deleted_at", "")
+
+	// $inc creates the field as 1 on insert and bumps it on update
+	if _, ok := model.(versioned); ok {
+		update = append(update, bson.E{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}})
+	}
+
+	return update, nil
+}
+
+// prepareUpsert applies the tenant and metadata to the model, validates it
+// and returns the filter and update of an upsert
+// A nil filter matches the model's _id. created_at is stamped in
+// $setOnInsert even when the model arrives with an ID but no creation time,
+// as natural keys do
+func prepareUpsert(ctx context.Context, model Model, filter interface{}) (interface{}, bson.D, error) {
+	if err := stampTenant(ctx, model); err != nil {
+		return nil, nil, err
+	}
+	id, ok := model.(identified)
+	if !ok {
+		return nil, nil, ErrInvalidID
+	}
+	if !id.hasID() {
+		setInsertMeta(ctx, model)
+	}
+	if err := requireID(model); err != nil {
+		return nil, nil, err
+	}
+	setUpdateMeta(ctx, model)
+	if err := Validate(model); err != nil {
+		return nil, nil, err
+	}
+
+	match := filter
+	if match == nil {
+		match = bson.D{{Key: "_id", Value: id.documentID()}}
+	}
+	matchesID, err := hasIDCondition(match)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	update, err := upsertDocument(model, !matchesID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if model.GetCreatedAt().IsZero() {
+		for i, op := range update {
+			if op.Key != "$setOnInsert" {
+				continue
+			}
+			onInsert := op.Value.(bson.D)
+			onInsert = setPath(onInsert, "created_at", timeNow())
+			if actor, ok := ActorFromContext(ctx); ok {
+				if _, isAuditable := model.(auditable); isAuditable {
+					onInsert = setPath(onInsert, "created_by", actor)
+				}
+			}
+			update[i].Value = onInsert
+		}
+	}
+
+	return match, update, nil
+}
+
+// upsertMatch returns the filter an upsert of the model matches on before
+// its metadata is applied: the given filter, the model's _id, or nil when a
+// model without an ID can only be inserted
+func upsertMatch(filter interface{}, model identified) interface{} {
+	if filter != nil {
+		return filter
+	}
+	if model.hasID() {
+		return bson.M{"_id": model.documentID()}
+	}
+	return nil
+}
+
+// hasIDCondition reports whether the filter matches on _id at the top level,
+// in which case an upsert takes the _id from the filter
+func hasIDCondition(filter interface{}) (bool, error) {
+	doc, err := normalizeDocument(filter)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// updateManyDocument merges the metadata maintained by the repository into
+// the update of an UpdateMany: updated_at, updated_by for auditable models
+// with an actor in the context, and an incremented version for versioned
+// models so stale copies fail their next Update
+// Update pipelines receive the same changes as a trailing $set stage
+func updateManyDocument(ctx context.Context, model Model, update interface{}) (interface{}, error) {
+	// Wrapping lets pipelines, which are arrays, be normalized too
+	doc, err := normalizeDocument(bson.D{{Key: "u", Value: update}})
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.D{{Key: "updated_at", Value: timeNow()}}
+	if actor, ok := ActorFromContext(ctx); ok {
+		if _, isAuditable := model.(auditable); isAuditable {
+			set = append(set, bson.E{Key: "updated_by", Value: actor})
+		}
+	}
+	_, isVersioned := model.(versioned)
+
+	switch u := doc[0].Value.(type) {
+	case bson.D:
+		for _, e := range set {
+			u = setPath(u, "$set."+e.Key, e.Value)
+		}
+		if isVersioned {
+			u = setPath(u, "$inc.version", 1)
+		}
+		return u, nil
+	case bson.A:
+		stage := bson.D{}
+		for _, e := range set {
+			stage = append(stage, bson.E{Key: e.Key, Value: bson.D{{Key: "$literal", Value: e.Value}}})
+		}
+		if isVersioned {
+			inc := bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$version", 0}}}, 1}}}
+			stage = append(stage, bson.E{Key: "version", Value: inc})
+		}
+		return append(u, bson.D{{Key: "$set", Value: stage}}), nil
+	}
+
+	return update, nil
+}
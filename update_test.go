@@ -0,0 +1,144 @@
+package basemodel
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestProfile is a test struct with optional fields
+type TestProfile struct {
+	BaseCollection `bson:",inline"`
+	Name           string  `json:"name" bson:"name"`
+	Nickname       string  `json:"nickname,omitempty" bson:"nickname,omitempty"`
+	Bio            *string `json:"bio,omitempty" bson:"bio,omitempty"`
+}
+
+// updateSection returns the named operator section of an update document
+func updateSection(t *testing.T, update bson.D, op string) bson.Raw {
+	t.Helper()
+
+	data, err := bson.Marshal(update)
+	if err != nil {
+		t.Fatalf("Failed to marshal update: %v", err)
+	}
+	section, err := bson.Raw(data).LookupErr(op)
+	if err != nil {
+		return nil
+	}
+	return section.Document()
+}
+
+func TestBuildUpdate(t *testing.T) {
+	user := &TestUser{Name: "John Doe", Email: "john@example.com"}
+	user.SetInsertMeta()
+
+	update, err := BuildUpdate(user)
+	if err != nil {
+		t.Fatalf("BuildUpdate returned error: %v", err)
+	}
+
+	if user.UpdatedAt == nil {
+		t.Error("Expected BuildUpdate to set UpdatedAt")
+	}
+
+	set := updateSection(t, update, "$set")
+	for _, key := range []string{"name", "email", "updated_at"} {
+		if _, err := set.LookupErr(key); err != nil {
+			t.Errorf("Expected $set to include %s, got %s", key, set)
+		}
+	}
+	for _, key := range []string{"_id", "created_at", "deleted_at"} {
+		if _, err := set.LookupErr(key); err == nil {
+			t.Errorf("Expected $set to never include %s", key)
+		}
+	}
+	if updateSection(t, update, "$unset") != nil {
+		t.Error("Expected no $unset without a snapshot")
+	}
+}
+
+func TestBuildUpdateUnsetsClearedFields(t *testing.T) {
+	bio := "Hello"
+	profile := &TestProfile{Name: "John Doe", Bio: &bio}
+	profile.SetInsertMeta()
+
+	profile.Bio = nil
+	update, err := BuildUpdate(profile)
+	if err != nil {
+		t.Fatalf("BuildUpdate returned error: %v", err)
+	}
+
+	unset := updateSection(t, update, "$unset")
+	if unset == nil {
+		t.Fatal("Expected $unset for the cleared fields")
+	}
+	for _, key := range []string{"bio", "nickname"} {
+		if _, err := unset.LookupErr(key); err != nil {
+			t.Errorf("Expected %s in $unset, got %s", key, unset)
+		}
+	}
+	for _, key := range []string{"deleted_at", "updated_at"} {
+		if _, err := unset.LookupErr(key); err == nil {
+			t.Errorf("Expected %s never to be unset, got %s", key, unset)
+		}
+	}
+}
+
+func TestBuildUpdateDiff(t *testing.T) {
+	snapshot := &TestProfile{Name: "John Doe", Nickname: "JD"}
+	snapshot.SetInsertMeta()
+
+	profile := *snapshot
+	profile.Name = "John Smith"
+	profile.Nickname = ""
+
+	update, err := BuildUpdateDiff(&profile, snapshot)
+	if err != nil {
+		t.Fatalf("BuildUpdateDiff returned error: %v", err)
+	}
+
+	set := updateSection(t, update, "$set")
+	if set.Lookup("name").StringValue() != "John Smith" {
+		t.Errorf("Expected changed name in $set, got %s", set)
+	}
+	if _, err := set.LookupErr("updated_at"); err != nil {
+		t.Error("Expected $set to always include updated_at")
+	}
+	if elems, _ := set.Elements(); len(elems) != 2 {
+		t.Errorf("Expected only name and updated_at in $set, got %s", set)
+	}
+
+	unset := updateSection(t, update, "$unset")
+	if unset == nil {
+		t.Fatal("Expected $unset for the cleared nickname")
+	}
+	if _, err := unset.LookupErr("nickname"); err != nil {
+		t.Errorf("Expected nickname in $unset, got %s", unset)
+	}
+}
+
+func TestBuildUpdateProtectsVersionAndAudit(t *testing.T) {
+	account := &TestAccount{Balance: 100}
+	account.SetInsertMeta()
+
+	update, err := BuildUpdate(account)
+	if err != nil {
+		t.Fatalf("BuildUpdate returned error: %v", err)
+	}
+	if _, err := updateSection(t, update, "$set").LookupErr("version"); err == nil {
+		t.Error("Expected version to be excluded for versioned models")
+	}
+
+	invoice := &TestInvoice{Amount: 100}
+	invoice.SetInsertMeta()
+	invoice.CreatedBy = "alice"
+
+	update, err = BuildUpdate(invoice)
+	if err != nil {
+		t.Fatalf("BuildUpdate returned error: %v", err)
+	}
+	if _, err := updateSection(t, update, "$set").LookupErr("created_by"); err == nil {
+		t.Error("Expected created_by to be excluded for auditable models")
+	}
+}
@@ -0,0 +1,327 @@
+package basemodel
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Validator is implemented by models with custom validation logic
+// It runs after the struct tag rules
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a single failed validation rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists every failed rule of a model
+// Fields are named by their bson name so they match the stored document
+type ValidationErrors []FieldError
+
+// Error implements the error interface
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "basemodel: validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the model against its `validate` struct tags and, when the
+// model implements Validator, its Validate method
+// It returns ValidationErrors when any rule fails
+//
+// Supported rules, separated by commas:
+//
+//	required       value must not be the zero value
+//	omitempty      skip the remaining rules when the value is the zero value
+//	min=N, max=N   numeric bounds, or length bounds for strings, slices and maps
+//	len=N          exact length for strings, slices and maps
+//	email          value must be a plain email address
+//	enum=a|b|c     value must be one of the listed values
+//	regex=PATTERN  value must match the pattern; must be the last rule
+//
+// Every rule also checks zero values, since 0 is a real quantity; tag
+// optional fields omitempty so they are only checked when set
+func Validate(model interface{}) error {
+	var errs ValidationErrors
+
+	v := reflect.ValueOf(model)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		if err := validateStruct(v, "", &errs); err != nil {
+			return err
+		}
+	}
+
+	if validator, ok := model.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			var verrs ValidationErrors
+			var ferr FieldError
+			switch {
+			case errors.As(err, &verrs):
+				errs = append(errs, verrs...)
+			case errors.As(err, &ferr):
+				errs = append(errs, ferr)
+			default:
+				errs = append(errs, FieldError{Rule: "custom", Message: err.Error()})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// validateStruct applies the tag rules of every field in v
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, inline := bsonFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		value := v.Field(i)
+		if inline {
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				if err := validateStruct(value, prefix, errs); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		path := prefix + name
+		if tag, ok := field.Tag.Lookup("validate"); ok {
+			if err := validateField(value, path, tag, errs); err != nil {
+				return err
+			}
+		}
+
+		nested := value
+		if nested.Kind() == reflect.Ptr && !nested.IsNil() {
+			nested = nested.Elem()
+		}
+		if nested.Kind() == reflect.Struct && nested.Type() != timeType {
+			if err := validateStruct(nested, path+".", errs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// bsonFieldName returns the bson key of a struct field and whether it is inlined
+// Only an explicit inline option inlines; like the driver, untagged embedded
+// structs are encoded as subdocuments
+func bsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("bson")
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			return "", true
+		}
+	}
+	if parts[0] != "" {
+		return parts[0], false
+	}
+
+	return strings.ToLower(field.Name), false
+}
+
+// validateField applies the rules of a single validate tag
+func validateField(value reflect.Value, path, tag string, errs *ValidationErrors) error {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value = reflect.Zero(value.Type().Elem())
+		} else {
+			value = value.Elem()
+		}
+	}
+
+	for _, rule := range splitRules(tag) {
+		name, arg, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "required":
+			if value.IsZero() {
+				*errs = append(*errs, FieldError{Field: path, Rule: name, Message: "is required"})
+				return nil
+			}
+			continue
+		case "omitempty":
+			if value.IsZero() {
+				return nil
+			}
+			continue
+		}
+
+		msg, err := checkRule(value, name, arg)
+		if err != nil {
+			return fmt.Errorf("basemodel: field %s: %w", path, err)
+		}
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: path, Rule: name, Message: msg})
+		}
+	}
+
+	return nil
+}
+
+// splitRules splits a validate tag into rules; a regex rule takes the rest of the tag
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		rule, rest, _ := strings.Cut(tag, ",")
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+		tag = rest
+	}
+	return rules
+}
+
+// checkRule returns a failure message when the value breaks the rule
+func checkRule(value reflect.Value, name, arg string) (string, error) {
+	switch name {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s rule %q", name, arg)
+		}
+		size, isLength, ok := measure(value)
+		if !ok {
+			return "", fmt.Errorf("%s rule is not supported for %s", name, value.Kind())
+		}
+		unit := ""
+		if isLength {
+			unit = " in length"
+		}
+		switch {
+		case name == "min" && size < limit:
+			return fmt.Sprintf("must be at least %s%s", arg, unit), nil
+		case name == "max" && size > limit:
+			return fmt.Sprintf("must be at most %s%s", arg, unit), nil
+		case name == "len" && (!isLength || size != limit):
+			return fmt.Sprintf("must be exactly %s in length", arg), nil
+		}
+	case "email":
+		s, ok := stringValue(value)
+		if !ok {
+			return "", fmt.Errorf("email rule requires a string")
+		}
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return "must be a valid email address", nil
+		}
+	case "enum":
+		actual := fmt.Sprint(value.Interface())
+		for _, allowed := range strings.Split(arg, "|") {
+			if actual == allowed {
+				return "", nil
+			}
+		}
+		return "must be one of " + strings.ReplaceAll(arg, "|", ", "), nil
+	case "regex":
+		s, ok := stringValue(value)
+		if !ok {
+			return "", fmt.Errorf("regex rule requires a string")
+		}
+		re, err := compileRule(arg)
+		if err != nil {
+			return "", err
+		}
+		if !re.MatchString(s) {
+			return "must match " + arg, nil
+		}
+	default:
+		return "", fmt.Errorf("unknown validation rule %q", name)
+	}
+
+	return "", nil
+}
+
+// measure returns the numeric value or length used by min, max and len
+func measure(value reflect.Value) (size float64, isLength bool, ok bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false, true
+	}
+	return 0, false, false
+}
+
+func stringValue(value reflect.Value) (string, bool) {
+	if value.Kind() != reflect.String {
+		return "", false
+	}
+	return value.String(), true
+}
+
+var regexCache sync.Map
+
+// compileRule compiles and caches a regex rule
+func compileRule(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex rule: %w", err)
+	}
+	regexCache.Store(pattern, re)
+
+	return re, nil
+}
@@ -0,0 +1,224 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestSignup is a test struct using every built-in validation rule
+type TestSignup struct {
+	BaseCollection `bson:",inline"`
+	Username       string   `json:"username" bson:"username" validate:"required,min=3,max=12"`
+	Email          string   `json:"email" bson:"email_address" validate:"required,email"`
+	Age            int      `json:"age" bson:"age" validate:"omitempty,min=18,max=120"`
+	Plan           string   `json:"plan" bson:"plan" validate:"omitempty,enum=free|pro|team"`
+	Zip            string   `json:"zip" bson:"zip" validate:"omitempty,len=5"`
+	Handle         string   `json:"handle" bson:"handle" validate:"omitempty,regex=^[a-z]{1,3}(_[a-z]+)?$"`
+	Tags           []string `json:"tags" bson:"tags" validate:"omitempty,max=2"`
+}
+
+// TestCoupon is a test struct with a custom Validate method
+type TestCoupon struct {
+	BaseCollection `bson:",inline"`
+	Code           string `json:"code" bson:"code" validate:"required"`
+	Percent        int    `json:"percent" bson:"percent"`
+}
+
+func (c *TestCoupon) Validate() error {
+	if c.Percent > 100 {
+		return FieldError{Field: "percent", Rule: "custom", Message: "must not exceed 100"}
+	}
+	return nil
+}
+
+// fieldRules maps each failed field to its rule
+func fieldRules(t *testing.T, err error) map[string]string {
+	t.Helper()
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	rules := make(map[string]string, len(verrs))
+	for _, fe := range verrs {
+		rules[fe.Field] = fe.Rule
+	}
+	return rules
+}
+
+func TestValidateValid(t *testing.T) {
+	signup := &TestSignup{
+		Username: "john",
+		Email:    "john@example.com",
+		Age:      30,
+		Plan:     "pro",
+		Zip:      "10110",
+		Handle:   "jd_smith",
+		Tags:     []string{"a", "b"},
+	}
+
+	if err := Validate(signup); err != nil {
+		t.Errorf("Expected valid signup, got %v", err)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	signup := &TestSignup{
+		Username: "jo",
+		Email:    "not-an-email",
+		Age:      12,
+		Plan:     "gold",
+		Zip:      "123",
+		Handle:   "John",
+		Tags:     []string{"a", "b", "c"},
+	}
+
+	rules := fieldRules(t, Validate(signup))
+	expected := map[string]string{
+		"username":      "min",
+		"email_address": "email",
+		"age":           "min",
+		"plan":          "enum",
+		"zip":           "len",
+		"handle":        "regex",
+		"tags":          "max",
+	}
+	for field, rule := range expected {
+		if rules[field] != rule {
+			t.Errorf("Expected %s to fail %s, got %q", field, rule, rules[field])
+		}
+	}
+}
+
+func TestValidateRequiredAndOptional(t *testing.T) {
+	rules := fieldRules(t, Validate(&TestSignup{}))
+
+	if rules["username"] != "required" || rules["email_address"] != "required" {
+		t.Errorf("Expected required errors for username and email_address, got %v", rules)
+	}
+	if _, ok := rules["age"]; ok {
+		t.Error("Expected optional zero fields to skip their rules")
+	}
+}
+
+func TestValidateZeroValues(t *testing.T) {
+	type line struct {
+		Quantity int    `bson:"quantity" validate:"min=1"`
+		Status   string `bson:"status" validate:"enum=open|closed"`
+		Note     string `bson:"note" validate:"omitempty,min=3"`
+	}
+
+	rules := fieldRules(t, Validate(&line{}))
+	if rules["quantity"] != "min" {
+		t.Errorf("Expected a zero quantity to fail min, got %v", rules)
+	}
+	if rules["status"] != "enum" {
+		t.Errorf("Expected an empty status to fail enum, got %v", rules)
+	}
+	if _, ok := rules["note"]; ok {
+		t.Error("Expected omitempty to skip the rules of a zero note")
+	}
+
+	if rules := fieldRules(t, Validate(&line{Quantity: 1, Status: "open", Note: "ok"})); rules["note"] != "min" {
+		t.Errorf("Expected omitempty fields to be checked when set, got %v", rules)
+	}
+}
+
+// TestAddress is embedded without a bson tag, so it is a subdocument
+type TestAddress struct {
+	City string `json:"city" bson:"city,omitempty" validate:"required" index:""`
+}
+
+func TestValidateUntaggedEmbeddedStruct(t *testing.T) {
+	type shipment struct {
+		BaseCollection `bson:",inline"`
+		TestAddress
+	}
+
+	raw, err := bson.Marshal(&shipment{TestAddress: TestAddress{City: "Bangkok"}})
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+	if _, err := bson.Raw(raw).LookupErr("testaddress", "city"); err != nil {
+		t.Fatalf("Expected the driver to encode a subdocument, got %s", bson.Raw(raw))
+	}
+
+	if rules := fieldRules(t, Validate(&shipment{})); rules["testaddress.city"] != "required" {
+		t.Errorf("Expected the error on testaddress.city, got %v", rules)
+	}
+	if keys := omitEmptyFields(reflect.TypeOf(&shipment{})); slices.Contains(keys, "city") {
+		t.Errorf("Expected no top level city to unset, got %v", keys)
+	}
+
+	specs, err := ModelIndexes(&shipment{})
+	if err != nil {
+		t.Fatalf("ModelIndexes returned error: %v", err)
+	}
+	if _, ok := indexNamed(specs, "testaddress.city_1"); !ok {
+		t.Errorf("Expected an index on testaddress.city, got %+v", specs)
+	}
+}
+
+func TestValidateCustomValidator(t *testing.T) {
+	rules := fieldRules(t, Validate(&TestCoupon{Percent: 150}))
+
+	if rules["code"] != "required" {
+		t.Errorf("Expected tag rules to run, got %v", rules)
+	}
+	if rules["percent"] != "custom" {
+		t.Errorf("Expected custom Validate error to be included, got %v", rules)
+	}
+}
+
+func TestValidateInvalidRule(t *testing.T) {
+	type badRule struct {
+		Name string `bson:"name" validate:"shout"`
+	}
+
+	err := Validate(&badRule{Name: "x"})
+	if err == nil {
+		t.Fatal("Expected an error for an unknown rule")
+	}
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		t.Error("Expected a configuration error, not ValidationErrors")
+	}
+}
+
+func TestRepositoryValidatesBeforeWrite(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("create rejects invalid documents", func(mt *mtest.T) {
+		repo := NewRepository[TestSignup](mt.Coll)
+
+		err := repo.Create(context.Background(), &TestSignup{Username: "john"})
+		rules := fieldRules(mt.T, err)
+		if rules["email_address"] != "required" {
+			mt.Errorf("Expected email_address to be required, got %v", rules)
+		}
+		if evt := mt.GetStartedEvent(); evt != nil {
+			mt.Errorf("Expected no command to be sent, got %s", evt.CommandName)
+		}
+	})
+
+	mt.Run("update rejects invalid documents", func(mt *mtest.T) {
+		repo := NewRepository[TestCoupon](mt.Coll)
+
+		coupon := &TestCoupon{Code: "SAVE", Percent: 150}
+		coupon.SetInsertMeta()
+		rules := fieldRules(mt.T, repo.Update(context.Background(), coupon))
+		if rules["percent"] != "custom" {
+			mt.Errorf("Expected percent to be rejected, got %v", rules)
+		}
+		if evt := mt.GetStartedEvent(); evt != nil {
+			mt.Errorf("Expected no command to be sent, got %s", evt.CommandName)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package basemodel
+
+// VersionFields holds the version counter used for optimistic concurrency
+// Embed it inline next to BaseCollection or Base, together with any other
+// *Fields mixin; repositories start the version at 1 on insert, only apply
+// updates when the stored version matches the in-memory one, and increment it
+// atomically
+type VersionFields struct {
+	Version int64 `json:"version" bson:"version"`
+}
+
+// GetVersion returns the current version
+func (v *VersionFields) GetVersion() int64 {
+	return v.Version
+}
+
+// setVersion records the version stored after a successful update
+func (v *VersionFields) setVersion(version int64) {
+	v.Version = version
+}
+
+// BaseVersioned extends BaseCollection with a version counter
+// Repositories use it for optimistic concurrency: updates only apply when the
+// stored version matches the in-memory one, and increment it atomically
+type BaseVersioned struct {
+	BaseCollection `bson:",inline"`
+	VersionFields  `bson:",inline"`
+}
+
+// SetInsertMeta sets the metadata for insert operations
+// It generates a new ObjectID, sets the CreatedAt timestamp and starts the version at 1
+func (b *BaseVersioned) SetInsertMeta() {
+	b.BaseCollection.SetInsertMeta()
+	b.Version = 1
+}
+
+// versioned is implemented by models that embed VersionFields
+type versioned interface {
+	GetVersion() int64
+	setVersion(version int64)
+}
@@ -0,0 +1,148 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestAccount is a test struct that embeds BaseVersioned
+type TestAccount struct {
+	BaseVersioned `bson:",inline"`
+	Balance       int64 `json:"balance" bson:"balance"`
+}
+
+func TestBaseVersionedSetInsertMeta(t *testing.T) {
+	account := &TestAccount{Balance: 100}
+	account.SetInsertMeta()
+
+	if account.Oid.IsZero() {
+		t.Error("Expected Oid to be set after SetInsertMeta")
+	}
+	if account.GetVersion() != 1 {
+		t.Errorf("Expected version 1 after SetInsertMeta, got %d", account.GetVersion())
+	}
+}
+
+func TestRepositoryVersionedUpdate(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("increments version", func(mt *mtest.T) {
+		repo := NewRepository[TestAccount](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
+
+		account := &TestAccount{Balance: 100}
+		account.SetInsertMeta()
+		if err := repo.Update(context.Background(), account); err != nil {
+			mt.Fatalf("Update returned error: %v", err)
+		}
+		if account.GetVersion() != 2 {
+			mt.Errorf("Expected version 2 after Update, got %d", account.GetVersion())
+		}
+
+		stmt := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document()
+		filter := stmt.Lookup("q").Document().Lookup("$and").Array().Index(0).Value().Document()
+		if filter.Lookup("version").AsInt64() != 1 {
+			mt.Errorf("Expected filter on version 1, got %s", filter)
+		}
+
+		update := stmt.Lookup("u").Document()
+		if update.Lookup("$inc", "version").AsInt64() != 1 {
+			mt.Errorf("Expected $inc on version, got %s", update)
+		}
+		if _, err := update.LookupErr("$set", "version"); err == nil {
+			mt.Error("Expected version to be excluded from $set")
+		}
+		if _, err := update.LookupErr("$set", "balance"); err != nil {
+			mt.Error("Expected balance to be included in $set")
+		}
+	})
+
+	mt.Run("version conflict", func(mt *mtest.T) {
+		repo := NewRepository[TestAccount](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, "db.accounts", mtest.FirstBatch, bson.D{{Key: "n", Value: 1}}),
+		)
+
+		account := &TestAccount{Balance: 100}
+		account.SetInsertMeta()
+		err := repo.Update(context.Background(), account)
+		if !errors.Is(err, ErrVersionConflict) {
+			mt.Errorf("Expected ErrVersionConflict, got %v", err)
+		}
+		if account.GetVersion() != 1 {
+			mt.Errorf("Expected version to stay 1 after a conflict, got %d", account.GetVersion())
+		}
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewRepository[TestAccount](mt.Coll)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}, bson.E{Key: "nModified", Value: 0}),
+			mtest.CreateCursorResponse(0, "db.accounts", mtest.FirstBatch),
+		)
+
+		account := &TestAccount{Balance: 100}
+		account.SetInsertMeta()
+		err := repo.Update(context.Background(), account)
+		if !errors.Is(err, ErrNotFound) {
+			mt.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRepositoryVersionedUpdateMany(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("increments version", func(mt *mtest.T) {
+		repo := NewRepository[TestAccount](mt.Coll)
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 2}))
+
+		update := bson.M{"$set": bson.M{"balance": 0}, "$inc": bson.M{"fees": 1}}
+		if _, err := repo.UpdateMany(context.Background(), bson.M{}, update); err != nil {
+			mt.Fatalf("UpdateMany returned error: %v", err)
+		}
+
+		sent := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u").Document()
+		if sent.Lookup("$inc", "version").AsInt64() != 1 {
+			mt.Errorf("Expected $inc on version, got %s", sent)
+		}
+		if sent.Lookup("$inc", "fees").AsInt64() != 1 {
+			mt.Errorf("Expected the caller's $inc to be kept, got %s", sent)
+		}
+	})
+}
+
+func TestMemoryRepositoryVersionedUpdateMany(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[TestAccount]()
+
+	account := &TestAccount{Balance: 100}
+	if err := repo.Create(ctx, account); err != nil {
+		t.Fatalf("Create returned error: %v", err)
+	}
+	stale, err := repo.FindByID(ctx, account.GetID())
+	if err != nil {
+		t.Fatalf("FindByID returned error: %v", err)
+	}
+
+	if _, err := repo.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"balance": 999}}); err != nil {
+		t.Fatalf("UpdateMany returned error: %v", err)
+	}
+	updated, _ := repo.FindByID(ctx, account.GetID())
+	if updated.GetVersion() != 2 {
+		t.Errorf("Expected version 2 after UpdateMany, got %d", updated.GetVersion())
+	}
+
+	stale.Balance = 0
+	if err := repo.Update(ctx, stale); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Expected ErrVersionConflict for a stale copy, got %v", err)
+	}
+	if current, _ := repo.FindByID(ctx, account.GetID()); current.Balance != 999 {
+		t.Errorf("Expected the stale write to be rejected, got balance %d", current.Balance)
+	}
+}
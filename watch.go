@@ -0,0 +1,269 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ChangeType classifies a change to a model
+type ChangeType string
+
+const (
+	ChangeInserted    ChangeType = "inserted"
+	ChangeUpdated     ChangeType = "updated"
+	ChangeSoftDeleted ChangeType = "soft_deleted"
+	ChangeRestored    ChangeType = "restored"
+	ChangeHardDeleted ChangeType = "hard_deleted"
+)
+
+// ChangeEvent is a single classified change of a model
+// Document holds the current full document, after AfterFind ran on it; it is
+// nil for hard deletes
+type ChangeEvent[PT any] struct {
+	Type        ChangeType
+	ID          string
+	Document    PT
+	ResumeToken bson.Raw
+}
+
+// ResumeTokenStore persists change stream resume tokens by consumer name
+// Load returns a nil token when nothing was saved yet
+type ResumeTokenStore interface {
+	Load(ctx context.Context, name string) (bson.Raw, error)
+	Save(ctx context.Context, name string, token bson.Raw) error
+}
+
+// WatchOption configures a change stream subscription
+type WatchOption func(*watchConfig)
+
+// watchConfig holds the settings applied by WatchOption
+type watchConfig struct {
+	store ResumeTokenStore
+	name  string
+}
+
+// WithResumeTokens saves the resume token under name after every handled
+// event and resumes from the saved token when the subscription starts
+func WithResumeTokens(store ResumeTokenStore, name string) WatchOption {
+	return func(c *watchConfig) {
+		c.store = store
+		c.name = name
+	}
+}
+
+// Watch subscribes to the changes of a collection and calls handler with
+// every insert, update, soft delete, restore and hard delete, decoded into
+// the model type. It blocks until ctx is cancelled, the stream ends or the
+// handler returns an error, which stops the subscription and is returned
+//
+//	err := basemodel.Watch[User](ctx, db.Collection("users"), func(ctx context.Context, e basemodel.ChangeEvent[*User]) error {
+//		log.Printf("%s %s", e.Type, e.ID)
+//		return nil
+//	}, basemodel.WithResumeTokens(store, "user-indexer"))
+//
+// For tenant models only changes of the context's tenant are delivered;
+// hard deletes carry no document and are therefore not delivered for them
+// Change streams require a replica set or sharded cluster
+func Watch[T any, PT document[T]](ctx context.Context, collection *mongo.Collection, handler func(context.Context, ChangeEvent[PT]) error, opts ...WatchOption) error {
+	var cfg watchConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	types := bson.A{"insert", "update", "replace", "delete"}
+	match := bson.D{{Key: "operationType", Value: bson.D{{Key: "$in", Value: types}}}}
+	tenant, err := tenantCondition(ctx, PT(new(T)))
+	if err != nil {
+		return err
+	}
+	for _, cond := range tenant {
+		match = append(match, bson.E{Key: "fullDocument." + cond.Key, Value: cond.Value})
+	}
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+
+	streamOpts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if cfg.store != nil {
+		token, err := cfg.store.Load(ctx, cfg.name)
+		if err != nil {
+			return fmt.Errorf("basemodel: load resume token: %w", err)
+		}
+		if token != nil {
+			streamOpts.SetResumeAfter(token)
+		}
+	}
+
+	stream, err := collection.Watch(ctx, pipeline, streamOpts)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.WithoutCancel(ctx))
+
+	for stream.Next(ctx) {
+		event, ok, err := decodeChange[T, PT](ctx, stream.Current)
+		if err != nil {
+			return err
+		}
+		if ok {
+			event.ResumeToken = stream.ResumeToken()
+			if err := handler(ctx, event); err != nil {
+				return err
+			}
+		}
+		if cfg.store != nil {
+			if err := cfg.store.Save(ctx, cfg.name, stream.ResumeToken()); err != nil {
+				return fmt.Errorf("basemodel: save resume token: %w", err)
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return nil
+}
+
+// changeDocument is the part of a change event Watch reads
+type changeDocument struct {
+	OperationType     string   `bson:"operationType"`
+	FullDocument      bson.Raw `bson:"fullDocument"`
+	DocumentKey       bson.Raw `bson:"documentKey"`
+	UpdateDescription struct {
+		UpdatedFields bson.Raw `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// decodeChange classifies a raw change event and runs AfterFind on its
+// document; ok is false for events that do not describe a document change
+func decodeChange[T any, PT document[T]](ctx context.Context, raw bson.Raw) (ChangeEvent[PT], bool, error) {
+	var event ChangeEvent[PT]
+
+	var change changeDocument
+	if err := bson.Unmarshal(raw, &change); err != nil {
+		return event, false, err
+	}
+
+	switch change.OperationType {
+	case "insert":
+		event.Type = ChangeInserted
+	case "replace":
+		event.Type = ChangeUpdated
+	case "update":
+		event.Type = classifyUpdate(change.UpdateDescription.UpdatedFields, change.UpdateDescription.RemovedFields)
+	case "delete":
+		event.Type = ChangeHardDeleted
+	default:
+		return event, false, nil
+	}
+
+	// The document key decodes into the model's typed _id, so ID has the same
+	// string form as GetID for every ID type
+	key := PT(new(T))
+	if err := bson.Unmarshal(change.DocumentKey, key); err != nil {
+		return event, false, err
+	}
+	event.ID = key.GetID()
+
+	// The full document is missing for deletes, and for updates when the
+	// document was deleted before the lookup ran
+	if len(change.FullDocument) > 0 {
+		model := PT(new(T))
+		if err := bson.Unmarshal(change.FullDocument, model); err != nil {
+			return event, false, err
+		}
+		if err := afterFind(ctx, model); err != nil {
+			return event, false, err
+		}
+		event.Document = model
+	}
+
+	return event, true, nil
+}
+
+// classifyUpdate tells soft deletes and restores apart from other updates by
+// the deleted_at transition
+func classifyUpdate(updated bson.Raw, removed []string) ChangeType {
+	for _, field := range removed {
+		if field == "deleted_at" {
+			return ChangeRestored
+		}
+	}
+
+	if value, err := updated.LookupErr("deleted_at"); err == nil {
+		if value.Type == bson.TypeNull {
+			return ChangeRestored
+		}
+		return ChangeSoftDeleted
+	}
+
+	return ChangeUpdated
+}
+
+// MongoResumeTokenStore keeps resume tokens in a collection, one document per consumer
+type MongoResumeTokenStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoResumeTokenStore creates a resume token store backed by the collection
+func NewMongoResumeTokenStore(collection *mongo.Collection) *MongoResumeTokenStore {
+	return &MongoResumeTokenStore{collection: collection}
+}
+
+// Load returns the saved token of the consumer
+func (s *MongoResumeTokenStore) Load(ctx context.Context, name string) (bson.Raw, error) {
+	var doc struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := s.collection.FindOne(ctx, bson.M{"_id": name}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Token, nil
+}
+
+// Save stores the token of the consumer
+func (s *MongoResumeTokenStore) Save(ctx context.Context, name string, token bson.Raw) error {
+	update := bson.M{"$set": bson.M{"token": token, "updated_at": timeNow()}}
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": name}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// MemoryResumeTokenStore keeps resume tokens in memory
+// It is intended for tests; tokens are lost when the process exits
+type MemoryResumeTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]bson.Raw
+}
+
+// NewMemoryResumeTokenStore creates an empty in-memory resume token store
+func NewMemoryResumeTokenStore() *MemoryResumeTokenStore {
+	return &MemoryResumeTokenStore{tokens: make(map[string]bson.Raw)}
+}
+
+// Load returns the saved token of the consumer
+func (s *MemoryResumeTokenStore) Load(ctx context.Context, name string) (bson.Raw, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tokens[name], nil
+}
+
+// Save stores a copy of the token of the consumer
+func (s *MemoryResumeTokenStore) Save(ctx context.Context, name string, token bson.Raw) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[name] = append(bson.Raw(nil), token...)
+	return nil
+}
@@ -0,0 +1,169 @@
+package basemodel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// changeEvent builds a raw change event as the server would send it
+func changeEvent(token string, op string, id primitive.ObjectID, extra ...bson.E) bson.D {
+	event := bson.D{
+		{Key: "_id", Value: bson.D{{Key: "_data", Value: token}}},
+		{Key: "operationType", Value: op},
+		{Key: "documentKey", Value: bson.D{{Key: "_id", Value: id}}},
+	}
+	return append(event, extra...)
+}
+
+func TestClassifyUpdate(t *testing.T) {
+	raw := func(d bson.D) bson.Raw {
+		data, _ := bson.Marshal(d)
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		updated  bson.D
+		removed  []string
+		expected ChangeType
+	}{
+		{"plain update", bson.D{{Key: "name", Value: "Jane"}}, nil, ChangeUpdated},
+		{"soft delete", bson.D{{Key: "deleted_at", Value: time.Now()}}, nil, ChangeSoftDeleted},
+		{"restore by unset", bson.D{{Key: "updated_at", Value: time.Now()}}, []string{"deleted_at"}, ChangeRestored},
+		{"restore by null", bson.D{{Key: "deleted_at", Value: nil}}, nil, ChangeRestored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyUpdate(raw(tt.updated), tt.removed); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	mt := newMockT(t)
+
+	mt.Run("classifies and decodes events", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		full := bson.E{Key: "fullDocument", Value: userDoc(id, "john")}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch,
+			changeEvent("t1", "insert", id, full),
+			changeEvent("t2", "update", id, full, bson.E{Key: "updateDescription", Value: bson.D{
+				{Key: "updatedFields", Value: bson.D{{Key: "deleted_at", Value: time.Now()}}},
+				{Key: "removedFields", Value: bson.A{}},
+			}}),
+			changeEvent("t3", "delete", id),
+		))
+
+		store := NewMemoryResumeTokenStore()
+		var events []ChangeEvent[*TestUser]
+		err := Watch[TestUser](context.Background(), mt.Coll, func(ctx context.Context, e ChangeEvent[*TestUser]) error {
+			events = append(events, e)
+			return nil
+		}, WithResumeTokens(store, "indexer"))
+		if err != nil {
+			mt.Fatalf("Watch returned error: %v", err)
+		}
+
+		if len(events) != 3 {
+			mt.Fatalf("Expected 3 events, got %d", len(events))
+		}
+		expected := []ChangeType{ChangeInserted, ChangeSoftDeleted, ChangeHardDeleted}
+		for i, e := range events {
+			if e.Type != expected[i] {
+				mt.Errorf("Event %d: expected %s, got %s", i, expected[i], e.Type)
+			}
+			if e.ID != id.Hex() {
+				mt.Errorf("Event %d: expected ID %s, got %s", i, id.Hex(), e.ID)
+			}
+		}
+		if events[0].Document == nil || events[0].Document.Name != "john" {
+			mt.Errorf("Expected the inserted document to be decoded, got %+v", events[0].Document)
+		}
+		if events[2].Document != nil {
+			mt.Error("Expected no document for a hard delete")
+		}
+
+		token, _ := store.Load(context.Background(), "indexer")
+		if token.Lookup("_data").StringValue() != "t3" {
+			mt.Errorf("Expected the last resume token to be saved, got %s", token)
+		}
+
+		opts := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$changeStream").Document()
+		if opts.Lookup("fullDocument").StringValue() != "updateLookup" {
+			mt.Error("Expected fullDocument updateLookup")
+		}
+	})
+
+	mt.Run("runs AfterFind on decoded documents", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		full := bson.E{Key: "fullDocument", Value: bson.D{{Key: "_id", Value: id}, {Key: "email", Value: "john@example.com"}}}
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.members", mtest.FirstBatch, changeEvent("t1", "insert", id, full)))
+
+		var member *TestMember
+		err := Watch[TestMember](context.Background(), mt.Coll, func(ctx context.Context, e ChangeEvent[*TestMember]) error {
+			member = e.Document
+			return nil
+		})
+		if err != nil {
+			mt.Fatalf("Watch returned error: %v", err)
+		}
+		if member == nil || strings.Join(member.Calls, ",") != "AfterFind" {
+			mt.Errorf("Expected AfterFind to run on the event document, got %+v", member)
+		}
+	})
+
+	mt.Run("resumes from the stored token", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch))
+
+		store := NewMemoryResumeTokenStore()
+		saved, _ := bson.Marshal(bson.D{{Key: "_data", Value: "t9"}})
+		_ = store.Save(context.Background(), "indexer", saved)
+
+		err := Watch[TestUser](context.Background(), mt.Coll, func(ctx context.Context, e ChangeEvent[*TestUser]) error {
+			return nil
+		}, WithResumeTokens(store, "indexer"))
+		if err != nil {
+			mt.Fatalf("Watch returned error: %v", err)
+		}
+
+		opts := mt.GetStartedEvent().Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$changeStream").Document()
+		if opts.Lookup("resumeAfter", "_data").StringValue() != "t9" {
+			mt.Errorf("Expected resumeAfter t9, got %s", opts)
+		}
+	})
+
+	mt.Run("stops on handler error", func(mt *mtest.T) {
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "db.users", mtest.FirstBatch,
+			changeEvent("t1", "delete", id),
+			changeEvent("t2", "delete", id),
+		))
+
+		store := NewMemoryResumeTokenStore()
+		boom := errors.New("index unavailable")
+		calls := 0
+		err := Watch[TestUser](context.Background(), mt.Coll, func(ctx context.Context, e ChangeEvent[*TestUser]) error {
+			calls++
+			return boom
+		}, WithResumeTokens(store, "indexer"))
+		if !errors.Is(err, boom) {
+			mt.Fatalf("Expected the handler error, got %v", err)
+		}
+		if calls != 1 {
+			mt.Errorf("Expected 1 call, got %d", calls)
+		}
+		if token, _ := store.Load(context.Background(), "indexer"); token != nil {
+			mt.Error("Expected no token to be saved for an unhandled event")
+		}
+	})
+}